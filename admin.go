@@ -0,0 +1,314 @@
+package cmd
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ettle/strcase"
+	"github.com/hamba/logger/v2"
+	"github.com/hamba/logger/v2/ctx"
+	"github.com/urfave/cli/v3"
+)
+
+// readyTimeout is the time the readiness checks are given to complete.
+const readyTimeout = 5 * time.Second
+
+// Admin flag constants declared for CLI use.
+const (
+	FlagAdminAddr        = "admin.addr"
+	FlagAdminTLSCert     = "admin.tls-cert"
+	FlagAdminTLSKey      = "admin.tls-key"
+	FlagAdminTLSClientCA = "admin.tls-client-ca"
+	FlagAdminBasicAuth   = "admin.basic-auth"
+	FlagAdminBearerToken = "admin.bearer-token"
+)
+
+// CategoryAdmin is the admin flag category.
+const CategoryAdmin = "Admin"
+
+// AdminFlags are flags that configure the admin server.
+var AdminFlags = Flags{
+	&cli.StringFlag{
+		Name:     FlagAdminAddr,
+		Category: CategoryAdmin,
+		Usage:    "The address to serve the metrics, profiling, health and version endpoints on, e.g. :8081.",
+		Sources:  cli.EnvVars(strcase.ToSNAKE(FlagAdminAddr)),
+	},
+	&cli.StringFlag{
+		Name:     FlagAdminTLSCert,
+		Category: CategoryAdmin,
+		Usage:    "The TLS certificate file of the admin server.",
+		Sources:  cli.EnvVars(strcase.ToSNAKE(FlagAdminTLSCert)),
+	},
+	&cli.StringFlag{
+		Name:     FlagAdminTLSKey,
+		Category: CategoryAdmin,
+		Usage:    "The TLS key file of the admin server.",
+		Sources:  cli.EnvVars(strcase.ToSNAKE(FlagAdminTLSKey)),
+	},
+	&cli.StringFlag{
+		Name:     FlagAdminTLSClientCA,
+		Category: CategoryAdmin,
+		Usage:    "The CA file client certificates are verified with. If set, clients must present a certificate.",
+		Sources:  cli.EnvVars(strcase.ToSNAKE(FlagAdminTLSClientCA)),
+	},
+	&cli.StringFlag{
+		Name:     FlagAdminBasicAuth,
+		Category: CategoryAdmin,
+		Usage:    "The basic auth credentials required by the admin endpoints, as user:password.",
+		Sources:  cli.EnvVars(strcase.ToSNAKE(FlagAdminBasicAuth)),
+	},
+	&cli.StringFlag{
+		Name:     FlagAdminBearerToken,
+		Category: CategoryAdmin,
+		Usage:    "The bearer token required by the admin endpoints.",
+		Sources:  cli.EnvVars(strcase.ToSNAKE(FlagAdminBearerToken)),
+	},
+}
+
+// AdminOptions optionally configures an admin server.
+type AdminOptions struct {
+	// Metrics is the handler serving the metrics on /metrics.
+	// If it is nil, /metrics is not served.
+	//
+	// The handler is returned by NewStatterWithHandler only for a prometheus
+	// or victoria metrics DSN without a host. A DSN with a host serves its
+	// metrics on its own server instead, so /metrics is then not served.
+	Metrics http.Handler
+
+	// LogLevel is the handler serving the log level on /loglevel, e.g.
+	// a LogLevel returned by NewLoggerWithLevel. If it is nil, /loglevel
+	// is not served.
+	LogLevel http.Handler
+}
+
+// AdminServer serves the admin endpoints over HTTP:
+//
+//	/metrics          the metrics, when a handler is given
+//	/loglevel         the log level, when a handler is given
+//	/debug/pprof/     the runtime profiles
+//	/healthz          the liveness of the process
+//	/readyz           the result of the readiness checks
+//	/version          the build information
+type AdminServer struct {
+	srv  *http.Server
+	addr net.Addr
+	log  *logger.Logger
+
+	mu     sync.Mutex
+	checks []readyCheck
+
+	closing atomic.Bool
+	done    chan struct{}
+}
+
+type readyCheck struct {
+	name  string
+	check func(context.Context) error
+}
+
+// NewAdminServer starts an admin server configured from the cli.
+// If no admin address is configured, nil is returned.
+func NewAdminServer(cmd *cli.Command, svc string, log *logger.Logger, opts *AdminOptions) (*AdminServer, error) {
+	addr := cmd.String(FlagAdminAddr)
+	if addr == "" {
+		//nolint:nilnil // There is no sentinel in this case.
+		return nil, nil
+	}
+	if opts == nil {
+		opts = &AdminOptions{}
+	}
+
+	sec, err := adminSecurity(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("starting admin server: %w", err)
+	}
+	tlsCfg, err := sec.tlsConfig()
+	if err != nil {
+		return nil, fmt.Errorf("starting admin server: %w", err)
+	}
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("starting admin server: %w", err)
+	}
+	if tlsCfg != nil {
+		// Probes cannot present a client certificate, so it is only
+		// verified if given, and required by the authenticated endpoints.
+		if tlsCfg.ClientAuth == tls.RequireAndVerifyClientCert {
+			tlsCfg.ClientAuth = tls.VerifyClientCertIfGiven
+		}
+		ln = tls.NewListener(ln, tlsCfg)
+	}
+
+	s := &AdminServer{
+		addr: ln.Addr(),
+		log:  log,
+		done: make(chan struct{}),
+	}
+
+	// The health endpoints are not authenticated, so they can be used as probes.
+	authMux := http.NewServeMux()
+	if opts.Metrics != nil {
+		authMux.Handle("/metrics", opts.Metrics)
+	}
+	if opts.LogLevel != nil {
+		authMux.Handle("/loglevel", opts.LogLevel)
+	}
+	authMux.HandleFunc("/debug/pprof/", pprof.Index)
+	authMux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	authMux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	authMux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	authMux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	authMux.Handle("/version", versionHandler(svc))
+
+	mux := http.NewServeMux()
+	mux.Handle("/", sec.authenticate("admin", authMux))
+	mux.HandleFunc("/healthz", func(rw http.ResponseWriter, _ *http.Request) {
+		_, _ = rw.Write([]byte("ok\n"))
+	})
+	mux.HandleFunc("/readyz", s.handleReady)
+
+	s.srv = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: time.Second,
+		IdleTimeout:       120 * time.Second,
+	}
+
+	go func() {
+		defer close(s.done)
+
+		if err := s.srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error(err.Error(), ctx.Str("server", "admin"))
+		}
+	}()
+
+	return s, nil
+}
+
+// adminSecurity returns the TLS and authentication configured by the admin flags.
+func adminSecurity(cmd *cli.Command) (serverSecurity, error) {
+	sec := serverSecurity{
+		certFile:     cmd.String(FlagAdminTLSCert),
+		keyFile:      cmd.String(FlagAdminTLSKey),
+		clientCAFile: cmd.String(FlagAdminTLSClientCA),
+		token:        cmd.String(FlagAdminBearerToken),
+	}
+	if auth := cmd.String(FlagAdminBasicAuth); auth != "" {
+		var err error
+		sec.user, sec.password, err = parseBasicAuth(auth)
+		if err != nil {
+			return serverSecurity{}, err
+		}
+	}
+	return sec, sec.validate()
+}
+
+// parseBasicAuth parses basic auth credentials in the form user:password.
+func parseBasicAuth(s string) (user, pass string, err error) {
+	user, pass, ok := strings.Cut(s, ":")
+	if !ok || user == "" || pass == "" {
+		return "", "", errors.New("must be in the form user:password")
+	}
+	return user, pass, nil
+}
+
+// Addr returns the address the server is listening on.
+func (s *AdminServer) Addr() net.Addr {
+	return s.addr
+}
+
+// AddReadyCheck adds a readiness check. The server is ready when all
+// checks succeed, and is no longer ready once it is being closed.
+func (s *AdminServer) AddReadyCheck(name string, check func(context.Context) error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.checks = append(s.checks, readyCheck{name: name, check: check})
+}
+
+func (s *AdminServer) handleReady(rw http.ResponseWriter, req *http.Request) {
+	if s.closing.Load() {
+		http.Error(rw, "shutting down", http.StatusServiceUnavailable)
+		return
+	}
+
+	s.mu.Lock()
+	checks := s.checks
+	s.mu.Unlock()
+
+	checkCtx, cancel := context.WithTimeout(req.Context(), readyTimeout)
+	defer cancel()
+
+	// The endpoint is not authenticated, so only the names of the
+	// failed checks are returned, while their errors are logged.
+	var failed []string
+	for _, c := range checks {
+		if err := c.check(checkCtx); err != nil {
+			s.log.Warn("Readiness check failed", ctx.Str("check", c.name), ctx.Err(err))
+			failed = append(failed, c.name)
+		}
+	}
+	if len(failed) > 0 {
+		http.Error(rw, strings.Join(failed, "\n"), http.StatusServiceUnavailable)
+		return
+	}
+	_, _ = rw.Write([]byte("ok\n"))
+}
+
+// Close shuts the server down gracefully, giving in-flight requests time to complete.
+func (s *AdminServer) Close() error {
+	s.closing.Store(true)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), metricsShutdownTimeout)
+	defer cancel()
+
+	err := s.srv.Shutdown(shutdownCtx)
+	<-s.done
+	return err
+}
+
+// buildVersion is the build information served on /version.
+type buildVersion struct {
+	Service   string `json:"service"`
+	Version   string `json:"version,omitempty"`
+	Revision  string `json:"revision,omitempty"`
+	Time      string `json:"time,omitempty"`
+	Modified  bool   `json:"modified,omitempty"`
+	GoVersion string `json:"goVersion"`
+}
+
+func versionHandler(svc string) http.Handler {
+	v := buildVersion{Service: svc, GoVersion: runtime.Version()}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		v.Version = info.Main.Version
+		for _, setting := range info.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				v.Revision = setting.Value
+			case "vcs.time":
+				v.Time = setting.Value
+			case "vcs.modified":
+				v.Modified = setting.Value == "true"
+			}
+		}
+	}
+
+	b, _ := json.Marshal(v)
+	return http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		_, _ = rw.Write(b)
+	})
+}
@@ -0,0 +1,157 @@
+package cmd_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/hamba/cmd/v3"
+	"github.com/hamba/logger/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v3"
+)
+
+func TestNewAdminServer(t *testing.T) {
+	log := logger.New(io.Discard, logger.LogfmtFormat(), logger.Error)
+
+	var admin *cmd.AdminServer
+	c := &cli.Command{
+		Flags: cmd.Flags{}.Merge(cmd.StatsFlags, cmd.AdminFlags),
+		Action: func(_ context.Context, c *cli.Command) error {
+			stats, metrics, err := cmd.NewStatterWithHandler(c, log)
+			if err != nil {
+				return err
+			}
+			t.Cleanup(func() { _ = stats.Close() })
+			stats.Counter("requests").Inc(2)
+
+			admin, err = cmd.NewAdminServer(c, "my-service", log, &cmd.AdminOptions{Metrics: metrics})
+			return err
+		},
+	}
+
+	err := c.Run(t.Context(), []string{"test", "--stats.dsn=prometheus://", "--stats.interval=10ms", "--admin.addr=127.0.0.1:0"})
+	require.NoError(t, err)
+	require.NotNil(t, admin)
+	t.Cleanup(func() { _ = admin.Close() })
+
+	assert.EventuallyWithT(t, func(c *assert.CollectT) {
+		assert.Contains(c, scrape(c, admin.Addr().String()), "requests 2")
+	}, time.Second, 10*time.Millisecond)
+
+	base := "http://" + admin.Addr().String()
+
+	status, body := get(t, base+"/healthz")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "ok\n", body)
+
+	status, body = get(t, base+"/version")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, `"service":"my-service"`)
+	assert.Contains(t, body, `"goVersion":"go`)
+
+	status, body = get(t, base+"/debug/pprof/cmdline")
+	assert.Equal(t, http.StatusOK, status)
+	assert.NotEmpty(t, body)
+
+	status, _ = get(t, base+"/readyz")
+	assert.Equal(t, http.StatusOK, status)
+
+	admin.AddReadyCheck("db", func(context.Context) error { return errors.New("not connected") })
+
+	status, body = get(t, base+"/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Equal(t, "db\n", body)
+}
+
+func TestNewAdminServer_NoAddr(t *testing.T) {
+	log := logger.New(io.Discard, logger.LogfmtFormat(), logger.Error)
+
+	c := &cli.Command{
+		Flags: cmd.AdminFlags,
+		Action: func(_ context.Context, c *cli.Command) error {
+			admin, err := cmd.NewAdminServer(c, "my-service", log, nil)
+			assert.Nil(t, admin)
+			return err
+		},
+	}
+
+	err := c.Run(t.Context(), []string{"test"})
+
+	require.NoError(t, err)
+}
+
+func TestNewAdminServer_ListenError(t *testing.T) {
+	log := logger.New(io.Discard, logger.LogfmtFormat(), logger.Error)
+	addr := freeAddr(t)
+
+	var admin *cmd.AdminServer
+	c := &cli.Command{
+		Flags: cmd.AdminFlags,
+		Action: func(_ context.Context, c *cli.Command) error {
+			var err error
+			admin, err = cmd.NewAdminServer(c, "my-service", log, nil)
+			return err
+		},
+	}
+	err := c.Run(t.Context(), []string{"test", "--admin.addr=" + addr})
+	require.NoError(t, err)
+	running := admin
+	t.Cleanup(func() { _ = running.Close() })
+
+	err = c.Run(t.Context(), []string{"test", "--admin.addr=" + addr})
+
+	assert.ErrorContains(t, err, "starting admin server")
+}
+
+func TestNewAdminServer_EmptyPassword(t *testing.T) {
+	log := logger.New(io.Discard, logger.LogfmtFormat(), logger.Error)
+
+	c := &cli.Command{
+		Flags: cmd.AdminFlags,
+		Action: func(_ context.Context, c *cli.Command) error {
+			_, err := cmd.NewAdminServer(c, "my-service", log, nil)
+			return err
+		},
+	}
+
+	err := c.Run(t.Context(), []string{"test", "--admin.addr=127.0.0.1:0", "--admin.basic-auth=user:"})
+
+	assert.ErrorContains(t, err, "must be in the form user:password")
+}
+
+func TestNewStatterWithHandler_NoHandler(t *testing.T) {
+	log := logger.New(io.Discard, logger.LogfmtFormat(), logger.Error)
+
+	c := &cli.Command{
+		Flags: cmd.StatsFlags,
+		Action: func(_ context.Context, c *cli.Command) error {
+			stats, metrics, err := cmd.NewStatterWithHandler(c, log)
+			if err != nil {
+				return err
+			}
+			assert.Nil(t, metrics)
+			return stats.Close()
+		},
+	}
+
+	err := c.Run(t.Context(), []string{"test", "--stats.dsn=l2met://"})
+
+	require.NoError(t, err)
+}
+
+func get(t *testing.T, url string) (int, string) {
+	t.Helper()
+
+	resp, err := http.Get(url)
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	b, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp.StatusCode, string(b)
+}
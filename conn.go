@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"net"
+	"sync"
+)
+
+// redialConn is a connection that is redialed when a write fails.
+// The connection is dialed on the first write when not yet connected.
+type redialConn struct {
+	network string
+	addr    string
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+func dialRedial(network, addr string) (*redialConn, error) {
+	conn, err := net.Dial(network, addr)
+	if err != nil {
+		return nil, err
+	}
+	return &redialConn{network: network, addr: addr, conn: conn}, nil
+}
+
+func (c *redialConn) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn != nil {
+		if n, err := c.conn.Write(p); err == nil {
+			return n, nil
+		}
+		_ = c.conn.Close()
+		c.conn = nil
+	}
+
+	conn, err := net.Dial(c.network, c.addr)
+	if err != nil {
+		return 0, err
+	}
+	c.conn = conn
+	return c.conn.Write(p)
+}
+
+func (c *redialConn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
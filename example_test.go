@@ -20,6 +20,37 @@ func ExampleNewLogger() {
 	_ = log
 }
 
+func ExampleNewLogWriter() {
+	var c *cli.Command // Get this from your action
+
+	w, err := cmd.NewLogWriter(c)
+	if err != nil {
+		// Handle error.
+		return
+	}
+	defer func() { _ = w.Close() }()
+
+	log, err := cmd.NewLoggerWithOptions(c, &cmd.LoggerOptions{Writer: w})
+	if err != nil {
+		// Handle error.
+		return
+	}
+
+	_ = log
+}
+
+func ExampleNewSlogLogger() {
+	var c *cli.Command // Get this from your action
+
+	log, err := cmd.NewSlogLogger(c)
+	if err != nil {
+		// Handle error.
+		return
+	}
+
+	_ = log
+}
+
 func ExampleNewStatter() {
 	var c *cli.Command // Get this from your action
 
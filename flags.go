@@ -1,8 +1,6 @@
 package cmd
 
-import (
-	"github.com/urfave/cli/v3"
-)
+import "github.com/urfave/cli/v3"
 
 // Flags represents a set of CLI flags.
 type Flags []cli.Flag
@@ -18,5 +16,5 @@ func (f Flags) Merge(flags ...Flags) Flags {
 	return m
 }
 
-// MonitoringFlags are flags that configure logging, stats, profiling and tracing.
-var MonitoringFlags = Flags{}.Merge(LogFlags, StatsFlags, ProfilingFlags, TracingFlags)
+// MonitoringFlags are flags that configure logging, stats, profiling, tracing and the admin server.
+var MonitoringFlags = Flags{}.Merge(LogFlags, StatsFlags, ProfilingFlags, TracingFlags, AdminFlags)
@@ -0,0 +1,32 @@
+package cmd_test
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/hamba/cmd/v3"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v3"
+)
+
+// newTestLogger runs a command with the log flags and the args,
+// returning the logger created by newFn, writing to w.
+func newTestLogger[T any](t *testing.T, newFn func(*cli.Command, *cmd.LoggerOptions) (T, error), w io.Writer, args ...string) T {
+	t.Helper()
+
+	var log T
+	c := &cli.Command{
+		Flags: cmd.LogFlags,
+		Action: func(_ context.Context, c *cli.Command) error {
+			var err error
+			log, err = newFn(c, &cmd.LoggerOptions{Writer: w})
+			return err
+		},
+	}
+
+	err := c.Run(t.Context(), append([]string{"test"}, args...))
+	require.NoError(t, err)
+
+	return log
+}
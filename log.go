@@ -1,13 +1,22 @@
 package cmd
 
 import (
+	"errors"
+	"fmt"
 	"io"
+	"math"
 	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/ettle/strcase"
 	"github.com/hamba/logger/v2"
 	"github.com/hamba/logger/v2/ctx"
 	"github.com/urfave/cli/v3"
+	otellog "go.opentelemetry.io/otel/log"
+	"gopkg.in/natefinch/lumberjack.v2"
 )
 
 // Log flag constants declared for CLI use.
@@ -15,6 +24,32 @@ const (
 	FlagLogFormat = "log.format"
 	FlagLogLevel  = "log.level"
 	FlagLogCtx    = "log.ctx"
+	FlagLogRedact = "log.redact"
+	FlagLogSample = "log.sample"
+	FlagLogCaller = "log.caller"
+
+	FlagLogTimestamps = "log.timestamps"
+	FlagLogTimeFormat = "log.time-format"
+	FlagLogGCPProject = "log.gcp-project"
+
+	FlagLogOutput = "log.output"
+	FlagLogSink   = "log.sink"
+
+	FlagLogFile           = "log.file"
+	FlagLogMaxSize        = "log.max-size"
+	FlagLogRotateInterval = "log.rotate-interval"
+	FlagLogMaxAge         = "log.max-age"
+	FlagLogMaxBackups     = "log.max-backups"
+	FlagLogCompress       = "log.compress"
+
+	FlagLogAsync          = "log.async"
+	FlagLogAsyncQueueSize = "log.async-queue-size"
+	FlagLogAsyncPolicy    = "log.async-policy"
+
+	FlagLogExporter         = "log.exporter"
+	FlagLogEndpoint         = "log.endpoint"
+	FlagLogEndpointInsecure = "log.endpoint-insecure"
+	FlagLogHeaders          = "log.headers"
 )
 
 // CategoryLog is the log flag category.
@@ -25,14 +60,14 @@ var LogFlags = Flags{
 	&cli.StringFlag{
 		Name:     FlagLogFormat,
 		Category: CategoryLog,
-		Usage:    "Specify the format of logs. Supported formats: 'logfmt', 'json', 'console'",
+		Usage:    "Specify the format of logs. Supported formats: 'logfmt', 'json', 'console', 'ecs', 'gcp', 'otel-json'",
 		Sources:  cli.EnvVars(strcase.ToSNAKE(FlagLogFormat)),
 	},
 	&cli.StringFlag{
 		Name:     FlagLogLevel,
 		Category: CategoryLog,
 		Value:    "info",
-		Usage:    "Specify the log level. e.g. 'debug', 'info', 'error'.",
+		Usage:    "Specify the log level, optionally followed by component levels. e.g. 'debug', 'info', 'info,db=debug,http=warn'.",
 		Sources:  cli.EnvVars(strcase.ToSNAKE(FlagLogLevel)),
 	},
 	&cli.StringMapFlag{
@@ -41,55 +76,530 @@ var LogFlags = Flags{
 		Usage:    "A list of context field appended to every log. Format: key=value.",
 		Sources:  cli.EnvVars(strcase.ToSNAKE(FlagLogCtx)),
 	},
+	&cli.StringSliceFlag{
+		Name:     FlagLogRedact,
+		Category: CategoryLog,
+		Usage:    "A list of field keys, or value patterns in the form '/pattern/', to redact from logs.",
+		Sources:  cli.EnvVars(strcase.ToSNAKE(FlagLogRedact)),
+	},
+	&cli.StringMapFlag{
+		Name:     FlagLogSample,
+		Category: CategoryLog,
+		Usage: "Sample repeated log lines, keeping the first lines with the same level and message in each interval " +
+			"and every nth line thereafter. Format: first=100,thereafter=100,interval=1s.",
+		Sources: cli.EnvVars(strcase.ToSNAKE(FlagLogSample)),
+	},
+	&cli.BoolFlag{
+		Name:     FlagLogCaller,
+		Category: CategoryLog,
+		Usage:    "Determines if the file and line of the call site are added to every log.",
+		Sources:  cli.EnvVars(strcase.ToSNAKE(FlagLogCaller)),
+	},
+	&cli.BoolFlag{
+		Name:     FlagLogTimestamps,
+		Category: CategoryLog,
+		Usage:    "Determines if a timestamp is added to every log.",
+		Sources:  cli.EnvVars(strcase.ToSNAKE(FlagLogTimestamps)),
+	},
+	&cli.StringFlag{
+		Name:     FlagLogTimeFormat,
+		Category: CategoryLog,
+		Usage:    "The format of times in logs. Supported: 'unix', 'iso8601', 'rfc3339' or a Go time layout.",
+		Sources:  cli.EnvVars(strcase.ToSNAKE(FlagLogTimeFormat)),
+	},
+	&cli.StringFlag{
+		Name:     FlagLogGCPProject,
+		Category: CategoryLog,
+		Usage:    "The Google Cloud project of the traces in the 'gcp' log format, to write trace IDs as trace resource names.",
+		Sources:  cli.EnvVars(strcase.ToSNAKE(FlagLogGCPProject)),
+	},
+	&cli.StringFlag{
+		Name:     FlagLogOutput,
+		Category: CategoryLog,
+		Usage: "The target to write logs to. Supported targets: 'stdout', 'stderr', 'syslog://unix:///dev/log', " +
+			"'syslog+udp://host:514', 'journald://'.",
+		Sources: cli.EnvVars(strcase.ToSNAKE(FlagLogOutput)),
+	},
+	&cli.StringSliceFlag{
+		Name:     FlagLogSink,
+		Category: CategoryLog,
+		Usage: "A log sink in the form 'destination?format=json&level=debug'. Supported destinations: 'stdout', 'stderr', " +
+			"'file:///path/to/file' and the log output targets. Can be repeated.",
+		Sources: cli.EnvVars(strcase.ToSNAKE(FlagLogSink)),
+	},
+	&cli.StringFlag{
+		Name:     FlagLogFile,
+		Category: CategoryLog,
+		Usage:    "The file to write logs to. Logs are written to stdout when not set.",
+		Sources:  cli.EnvVars(strcase.ToSNAKE(FlagLogFile)),
+	},
+	&cli.IntFlag{
+		Name:     FlagLogMaxSize,
+		Category: CategoryLog,
+		Usage:    "The maximum size in megabytes of the log file before it is rotated.",
+		Value:    100,
+		Sources:  cli.EnvVars(strcase.ToSNAKE(FlagLogMaxSize)),
+	},
+	&cli.DurationFlag{
+		Name:     FlagLogRotateInterval,
+		Category: CategoryLog,
+		Usage:    "The interval at which the log file is rotated, regardless of its size. The file is only rotated by size when not set.",
+		Sources:  cli.EnvVars(strcase.ToSNAKE(FlagLogRotateInterval)),
+	},
+	&cli.DurationFlag{
+		Name:     FlagLogMaxAge,
+		Category: CategoryLog,
+		Usage:    "The maximum age of rotated log files to retain. It does not rotate the log file. Rotated files are kept forever when not set.",
+		Sources:  cli.EnvVars(strcase.ToSNAKE(FlagLogMaxAge)),
+	},
+	&cli.IntFlag{
+		Name:     FlagLogMaxBackups,
+		Category: CategoryLog,
+		Usage:    "The maximum number of rotated log files to retain. All rotated files are kept when not set.",
+		Sources:  cli.EnvVars(strcase.ToSNAKE(FlagLogMaxBackups)),
+	},
+	&cli.BoolFlag{
+		Name:     FlagLogCompress,
+		Category: CategoryLog,
+		Usage:    "Determines if rotated log files are compressed using gzip.",
+		Sources:  cli.EnvVars(strcase.ToSNAKE(FlagLogCompress)),
+	},
+	&cli.BoolFlag{
+		Name:     FlagLogAsync,
+		Category: CategoryLog,
+		Usage:    "Determines if logs are written asynchronously through a bounded queue.",
+		Sources:  cli.EnvVars(strcase.ToSNAKE(FlagLogAsync)),
+	},
+	&cli.IntFlag{
+		Name:     FlagLogAsyncQueueSize,
+		Category: CategoryLog,
+		Usage:    "The number of log lines the asynchronous queue holds.",
+		Value:    1024,
+		Sources:  cli.EnvVars(strcase.ToSNAKE(FlagLogAsyncQueueSize)),
+	},
+	&cli.StringFlag{
+		Name:     FlagLogAsyncPolicy,
+		Category: CategoryLog,
+		Usage:    "The policy when the asynchronous queue is full. Supported: 'block', 'drop'.",
+		Value:    "block",
+		Sources:  cli.EnvVars(strcase.ToSNAKE(FlagLogAsyncPolicy)),
+	},
+	&cli.StringFlag{
+		Name:     FlagLogExporter,
+		Category: CategoryLog,
+		Usage:    "The log record exporter, used in addition to the log writer. Supported: 'otlphttp', 'otlpgrpc'.",
+		Sources:  cli.EnvVars(strcase.ToSNAKE(FlagLogExporter)),
+	},
+	&cli.StringFlag{
+		Name:     FlagLogEndpoint,
+		Category: CategoryLog,
+		Usage:    "The log exporter endpoint.",
+		Sources:  cli.EnvVars(strcase.ToSNAKE(FlagLogEndpoint)),
+	},
+	&cli.BoolFlag{
+		Name:     FlagLogEndpointInsecure,
+		Category: CategoryLog,
+		Usage:    "Determines if the log exporter endpoint is insecure.",
+		Sources:  cli.EnvVars(strcase.ToSNAKE(FlagLogEndpointInsecure)),
+	},
+	&cli.StringMapFlag{
+		Name:     FlagLogHeaders,
+		Category: CategoryLog,
+		Usage:    "A list of headers appended to every log export request.",
+		Sources:  cli.EnvVars(strcase.ToSNAKE(FlagLogHeaders)),
+	},
 }
 
 // LoggerOptions are options for creating a logger.
 type LoggerOptions struct {
+	// Writer is the writer log lines are written to. When not set, the
+	// writer is created with NewLogWriter and stays open for the life of
+	// the process. As a log file, syslog, journald or async logging hold
+	// resources, and async logging queues lines, create such a writer with
+	// NewLogWriter instead, closing it once the logger is no longer used.
 	Writer io.Writer
+	// Ctx are fields added to every log line, after the fields of the log ctx flag.
+	// Lines logged by the logger itself, such as level changes, carry them too.
+	Ctx []logger.Field
+
+	// Provider is the provider log records are exported to. It must be set
+	// when a log exporter is configured, created with NewLogProvider and
+	// shutdown once the logger is no longer used.
+	Provider otellog.LoggerProvider
 }
 
 // NewLogger returns a logger configured from the cli.
+// Its writer is never closed, see LoggerOptions.Writer.
 func NewLogger(cmd *cli.Command) (*logger.Logger, error) {
 	return NewLoggerWithOptions(cmd, &LoggerOptions{})
 }
 
 // NewLoggerWithOptions returns a logger configured from the cli.
+//
+// When component levels are configured, the level is checked as with NewLoggerWithLevel.
 func NewLoggerWithOptions(cmd *cli.Command, opts *LoggerOptions) (*logger.Logger, error) {
-	str := cmd.String(FlagLogLevel)
-	if str == "" {
-		str = "info"
+	log, _, err := newLogger(cmd, opts, false)
+	return log, err
+}
+
+// NewLoggerWithLevel returns a logger configured from the cli, along with
+// a handle to change its level at runtime.
+//
+// As the level is checked by the formatter rather than the logger, logging below
+// the current level is slightly more expensive than with a logger from NewLoggerWithOptions.
+func NewLoggerWithLevel(cmd *cli.Command, opts *LoggerOptions) (*logger.Logger, *LogLevel, error) {
+	return newLogger(cmd, opts, true)
+}
+
+func newLogger(cmd *cli.Command, opts *LoggerOptions, dynamic bool) (*logger.Logger, *LogLevel, error) {
+	lvl, components, err := parseLogLevel(cmd.String(FlagLogLevel))
+	if err != nil {
+		return nil, nil, err
 	}
 
-	lvl, err := logger.LevelFromString(str)
+	fmtr, err := newLogFormatter(cmd)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	fmtr := newLogFormatter(cmd)
+	red, err := parseRedactor(cmd.StringSlice(FlagLogRedact))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	smp, err := parseLogSampler(cmd.StringMap(FlagLogSample))
+	if err != nil {
+		return nil, nil, err
+	}
 
 	tags := cmd.StringMap(FlagLogCtx)
 
-	fields := make([]logger.Field, 0, len(tags))
+	fields := make([]logger.Field, 0, len(tags)+len(opts.Ctx))
 	for k, v := range tags {
 		fields = append(fields, ctx.Str(k, v))
 	}
+	fields = append(fields, opts.Ctx...)
 
 	w := opts.Writer
 	if w == nil {
-		w = os.Stdout
+		w, err = NewLogWriter(cmd)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var exp recordExporter
+	switch {
+	case opts.Provider != nil:
+		exp = newOTelExporter(opts.Provider)
+	case cmd.String(FlagLogExporter) != "":
+		// A provider created here could never be shutdown,
+		// losing the records it has batched.
+		return nil, nil, errors.New("the log exporter requires a provider: " +
+			"create it with NewLogProvider and set it as the logger Provider option")
+	}
+
+	// With a dynamic level, lines are logged at any level and filtered by
+	// the record writer, while with component levels only up to the most
+	// verbose of them, so that lines past it are never formatted.
+	var logLvl *LogLevel
+	if dynamic || len(components) > 0 {
+		logLvl = newLogLevel(lvl, components)
+		lvl = logLvl.maxLevel()
+		if dynamic {
+			lvl = logger.Trace
+		}
+	}
+	if sw, ok := asSinkWriter(w); ok {
+		fmtr = newTeeFormatter(sw.sinks[0].fmtr, sw.formatters()[1:]...)
+	}
+	lw, isLvlW := asLevelWriter(w)
+	if logLvl != nil || exp != nil || smp != nil || isLvlW {
+		fmtr = newRecordFormatter(fmtr, exp != nil)
+		if smp != nil {
+			// The sampler summaries are logged from the sampler ticker, so they
+			// are written past the level and the sampler, without a caller.
+			smpFmtr := fmtr
+			if red != nil {
+				smpFmtr = newRedactFormatter(smpFmtr, red)
+			}
+			smpW := recordWriter{w: w, lw: lw, exp: exp}
+			smp.log = logger.New(smpW, smpFmtr, logger.Trace).With(fields...)
+			if cw, ok := w.(closeHookWriter); ok {
+				cw.closeHooks().add(smp.close)
+			}
+		}
+		w = recordWriter{w: w, lw: lw, lvl: logLvl, smp: smp, exp: exp}
+	}
+	if cmd.Bool(FlagLogCaller) {
+		fmtr = newCallerFormatter(fmtr)
+	}
+	if red != nil {
+		// Redaction wraps the other formatters, so that
+		// redacted values are never recorded.
+		fmtr = newRedactFormatter(fmtr, red)
 	}
+	if dynamic {
+		// The gate wraps the other formatters, so that lines
+		// disabled by the level are not formatted at all.
+		fmtr = newLevelGateFormatter(fmtr, logLvl)
+	}
+
+	log := logger.New(w, fmtr, lvl).With(fields...)
+	if logLvl != nil {
+		logLvl.log = log
+	}
+	return log, logLvl, nil
+}
+
+// LogTimeFormat returns the log time format configured from the cli, as used
+// for logger.TimeFormat. An empty string is returned when it is not set.
+func LogTimeFormat(cmd *cli.Command) string {
+	switch format := cmd.String(FlagLogTimeFormat); format {
+	case "unix":
+		return logger.TimeFormatUnix
+	case "iso8601":
+		return logger.TimeFormatISO8601
+	case "rfc3339":
+		return time.RFC3339
+	default:
+		return format
+	}
+}
+
+// NewLogWriter returns the log writer configured from the cli.
+// If no log output or file is configured, stdout is returned, which
+// is not closed by the returned writer.
+//
+// When logs are written asynchronously, the writer is an *AsyncWriter,
+// which must be closed to write the queued logs.
+func NewLogWriter(cmd *cli.Command) (io.WriteCloser, error) {
+	w, err := newLogOutputWriter(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	if !cmd.Bool(FlagLogAsync) {
+		return w, nil
+	}
+
+	size := cmd.Int(FlagLogAsyncQueueSize)
+	if size <= 0 {
+		_ = w.Close()
+		return nil, errors.New("log async queue size must be positive")
+	}
+	block, err := parseAsyncPolicy(cmd.String(FlagLogAsyncPolicy))
+	if err != nil {
+		_ = w.Close()
+		return nil, err
+	}
+	return NewAsyncWriter(w, size, block), nil
+}
+
+// parseAsyncPolicy reports if the async policy blocks when the queue is full.
+func parseAsyncPolicy(policy string) (bool, error) {
+	switch policy {
+	case "", "block":
+		return true, nil
+	case "drop":
+		return false, nil
+	default:
+		return false, fmt.Errorf("unsupported log async policy %q", policy)
+	}
+}
+
+func newLogOutputWriter(cmd *cli.Command) (io.WriteCloser, error) {
+	output := cmd.String(FlagLogOutput)
+	file := cmd.String(FlagLogFile)
+	if output != "" && output != "stdout" && file != "" {
+		return nil, errors.New("log output and log file cannot both be set")
+	}
+	if sinks := cmd.StringSlice(FlagLogSink); len(sinks) > 0 {
+		if output != "" || file != "" {
+			return nil, errors.New("log sinks cannot be combined with log output or log file")
+		}
+		return newSinkWriter(cmd, sinks)
+	}
+
+	if file != "" {
+		return newLogFile(cmd, file)
+	}
+	return newLogTarget(cmd, output)
+}
+
+// validateLogTarget validates the log output target.
+func validateLogTarget(target string) error {
+	scheme, _, _ := strings.Cut(target, "://")
+	switch scheme {
+	case "", "stdout", "stderr":
+		return nil
+	case "syslog", "syslog+udp":
+		_, _, err := parseSyslogTarget(target)
+		return err
+	case "journald":
+		_, err := parseJournaldTarget(target)
+		return err
+	default:
+		return fmt.Errorf("unsupported log output %q", target)
+	}
+}
+
+// newLogTarget returns the writer for a log output target.
+func newLogTarget(cmd *cli.Command, target string) (io.WriteCloser, error) {
+	scheme, _, _ := strings.Cut(target, "://")
+	switch scheme {
+	case "", "stdout":
+		return &nopWriteCloser{Writer: os.Stdout}, nil
+	case "stderr":
+		return &nopWriteCloser{Writer: os.Stderr}, nil
+	case "syslog", "syslog+udp":
+		return newSyslogWriter(target, cmd.Root().Name, cmd.StringMap(FlagLogCtx))
+	case "journald":
+		return newJournaldWriter(target, cmd.Root().Name, cmd.StringMap(FlagLogCtx))
+	default:
+		return nil, fmt.Errorf("unsupported log output %q", target)
+	}
+}
+
+// newLogFile returns a log file writer, rotated as configured from the cli.
+func newLogFile(cmd *cli.Command, file string) (io.WriteCloser, error) {
+	maxSize := cmd.Int(FlagLogMaxSize)
+	if maxSize < 0 {
+		return nil, errors.New("log max size must not be negative")
+	}
+	interval := cmd.Duration(FlagLogRotateInterval)
+	if interval < 0 {
+		return nil, errors.New("log rotate interval must not be negative")
+	}
+	maxAge := cmd.Duration(FlagLogMaxAge)
+	if maxAge < 0 {
+		return nil, errors.New("log max age must not be negative")
+	}
+	maxBackups := cmd.Int(FlagLogMaxBackups)
+	if maxBackups < 0 {
+		return nil, errors.New("log max backups must not be negative")
+	}
+
+	f := &logFile{
+		Logger: &lumberjack.Logger{
+			Filename:   file,
+			MaxSize:    maxSize,
+			MaxAge:     int(math.Ceil(maxAge.Hours() / 24)),
+			MaxBackups: maxBackups,
+			Compress:   cmd.Bool(FlagLogCompress),
+		},
+		stop: make(chan struct{}),
+	}
+	if interval > 0 {
+		f.wg.Add(1)
+		go f.rotate(interval)
+	}
+	return f, nil
+}
+
+// logFile is a log file, rotated by size and optionally at an interval.
+type logFile struct {
+	*lumberjack.Logger
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+	hooks    closeHooks
+}
+
+// rotate rotates the log file every interval, until the file is closed.
+func (f *logFile) rotate(interval time.Duration) {
+	defer f.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-f.stop:
+			return
+		case <-ticker.C:
+			// A file that fails to rotate is written to until the next
+			// interval, and there is nowhere to report the error to.
+			_ = f.Logger.Rotate()
+		}
+	}
+}
+
+// Close stops the rotation and closes the log file.
+func (f *logFile) Close() error {
+	f.hooks.run()
+
+	f.stopOnce.Do(func() { close(f.stop) })
+	f.wg.Wait()
+
+	return f.Logger.Close()
+}
+
+type nopWriteCloser struct {
+	io.Writer
+
+	hooks closeHooks
+}
+
+func (w *nopWriteCloser) Close() error {
+	w.hooks.run()
+	return nil
+}
+
+// closeHooks are the functions a log writer runs before it is closed,
+// so that the lines they log are still written.
+type closeHooks struct {
+	mu  sync.Mutex
+	fns []func()
+}
+
+func (h *closeHooks) add(fn func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.fns = append(h.fns, fn)
+}
+
+// run runs the hooks once, in reverse order of their addition.
+func (h *closeHooks) run() {
+	h.mu.Lock()
+	fns := h.fns
+	h.fns = nil
+	h.mu.Unlock()
+
+	for _, fn := range slices.Backward(fns) {
+		fn()
+	}
+}
+
+// closeHookWriter is a log writer that runs hooks before it is closed.
+type closeHookWriter interface {
+	closeHooks() *closeHooks
+}
+
+func (f *logFile) closeHooks() *closeHooks        { return &f.hooks }
+func (w *nopWriteCloser) closeHooks() *closeHooks { return &w.hooks }
 
-	return logger.New(w, fmtr, lvl).With(fields...), nil
+func newLogFormatter(cmd *cli.Command) (logger.Formatter, error) {
+	return logFormatter(cmd, cmd.String(FlagLogFormat))
 }
 
-func newLogFormatter(cmd *cli.Command) logger.Formatter {
-	format := cmd.String(FlagLogFormat)
+func logFormatter(cmd *cli.Command, format string) (logger.Formatter, error) {
 	switch format {
+	case "", "logfmt":
+		return logger.LogfmtFormat(), nil
 	case "json":
-		return logger.JSONFormat()
+		return logger.JSONFormat(), nil
 	case "console":
-		return logger.ConsoleFormat()
+		return logger.ConsoleFormat(), nil
+	case "ecs":
+		return newECSFormatter(logger.JSONFormat()), nil
+	case "gcp":
+		return newGCPFormatter(logger.JSONFormat(), cmd.String(FlagLogGCPProject)), nil
+	case "otel-json":
+		return newOTelJSONFormatter(logger.JSONFormat()), nil
 	default:
-		return logger.LogfmtFormat()
+		return nil, fmt.Errorf("unsupported log format %q", format)
 	}
 }
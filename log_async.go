@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+
+	"github.com/hamba/logger/v2"
+	"github.com/hamba/statter/v2"
+)
+
+// ErrWriterClosed is returned when writing to a closed writer.
+var ErrWriterClosed = errors.New("writer closed")
+
+// AsyncWriter writes to a writer asynchronously through a bounded queue.
+//
+// When the queue is full, writes either block or are dropped. Dropped
+// writes are counted, and reported as the `log.dropped` counter once
+// a statter is set, including those dropped before it was set.
+type AsyncWriter struct {
+	w     io.Writer
+	lw    levelWriter
+	queue chan *asyncLine
+	block bool
+
+	dropped atomic.Int64
+	// unreported are the drops not yet reported to the statter.
+	unreported atomic.Int64
+	stats      atomic.Pointer[statter.Statter]
+
+	mu     sync.RWMutex
+	closed bool
+	done   chan struct{}
+
+	hooks closeHooks
+}
+
+// NewAsyncWriter returns an async writer with a queue of the given size.
+// If block is true, writes block when the queue is full, otherwise they are dropped.
+func NewAsyncWriter(w io.Writer, size int, block bool) *AsyncWriter {
+	lw, _ := asLevelWriter(w)
+	aw := &AsyncWriter{
+		w:     w,
+		lw:    lw,
+		queue: make(chan *asyncLine, size),
+		block: block,
+		done:  make(chan struct{}),
+	}
+
+	go aw.run()
+
+	return aw
+}
+
+type asyncLine struct {
+	lvl     logger.Level
+	leveled bool
+	buf     []byte
+}
+
+var asyncLinePool = sync.Pool{
+	New: func() any {
+		return &asyncLine{buf: make([]byte, 0, 512)}
+	},
+}
+
+func (w *AsyncWriter) run() {
+	defer close(w.done)
+
+	for line := range w.queue {
+		if line.leveled && w.lw != nil {
+			_, _ = w.lw.writeLevel(line.lvl, line.buf)
+		} else {
+			_, _ = w.w.Write(line.buf)
+		}
+		asyncLinePool.Put(line)
+	}
+}
+
+// Write queues p to be written.
+func (w *AsyncWriter) Write(p []byte) (int, error) {
+	return w.enqueue(0, false, p)
+}
+
+func (w *AsyncWriter) writeLevel(lvl logger.Level, p []byte) (int, error) {
+	return w.enqueue(lvl, true, p)
+}
+
+func (w *AsyncWriter) enqueue(lvl logger.Level, leveled bool, p []byte) (int, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	if w.closed {
+		return 0, ErrWriterClosed
+	}
+
+	line := asyncLinePool.Get().(*asyncLine)
+	line.lvl, line.leveled = lvl, leveled
+	line.buf = append(line.buf[:0], p...)
+
+	if w.block {
+		w.queue <- line
+		return len(p), nil
+	}
+
+	select {
+	case w.queue <- line:
+	default:
+		asyncLinePool.Put(line)
+		w.dropped.Add(1)
+		if stats := w.stats.Load(); stats != nil {
+			stats.Counter("log.dropped").Inc(1)
+			break
+		}
+
+		// The statter may have been set since it was loaded,
+		// in which case the drop is reported right away.
+		w.unreported.Add(1)
+		if stats := w.stats.Load(); stats != nil {
+			w.report(stats)
+		}
+	}
+	return len(p), nil
+}
+
+// report reports the drops not yet reported to the statter.
+func (w *AsyncWriter) report(stats *statter.Statter) {
+	if n := w.unreported.Swap(0); n > 0 {
+		stats.Counter("log.dropped").Inc(n)
+	}
+}
+
+// Dropped returns the number of dropped writes.
+func (w *AsyncWriter) Dropped() int64 {
+	return w.dropped.Load()
+}
+
+// SetStatter sets the statter dropped writes are reported to.
+// The writes dropped before it was set are reported to it at once.
+func (w *AsyncWriter) SetStatter(stats *statter.Statter) {
+	w.stats.Store(stats)
+	if stats != nil {
+		w.report(stats)
+	}
+}
+
+func (w *AsyncWriter) closeHooks() *closeHooks { return &w.hooks }
+
+// Close writes the queued writes and closes the underlying
+// writer if it is an io.Closer.
+func (w *AsyncWriter) Close() error {
+	w.hooks.run()
+
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	close(w.queue)
+	w.mu.Unlock()
+
+	<-w.done
+
+	if c, ok := w.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
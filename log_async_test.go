@@ -0,0 +1,103 @@
+package cmd_test
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hamba/cmd/v3"
+	"github.com/hamba/logger/v2"
+	"github.com/hamba/statter/v2"
+	"github.com/hamba/statter/v2/reporter/l2met"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAsyncWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := cmd.NewAsyncWriter(&buf, 10, true)
+
+	line := []byte("line 1\n")
+	_, err := w.Write(line)
+	require.NoError(t, err)
+	copy(line, "line 2\n")
+	_, err = w.Write(line)
+	require.NoError(t, err)
+
+	err = w.Close()
+
+	require.NoError(t, err)
+	assert.Equal(t, "line 1\nline 2\n", buf.String())
+	assert.Equal(t, int64(0), w.Dropped())
+}
+
+func TestAsyncWriter_Drop(t *testing.T) {
+	bw := &blockingWriter{unblock: make(chan struct{})}
+	w := cmd.NewAsyncWriter(bw, 1, false)
+
+	for range 10 {
+		_, err := w.Write([]byte("line\n"))
+		require.NoError(t, err)
+	}
+	close(bw.unblock)
+
+	err := w.Close()
+
+	require.NoError(t, err)
+	assert.Equal(t, 10, bw.lines()+int(w.Dropped()))
+	assert.GreaterOrEqual(t, w.Dropped(), int64(8))
+}
+
+func TestAsyncWriter_DropBeforeStatter(t *testing.T) {
+	bw := &blockingWriter{unblock: make(chan struct{})}
+	w := cmd.NewAsyncWriter(bw, 1, false)
+
+	for range 10 {
+		_, err := w.Write([]byte("line\n"))
+		require.NoError(t, err)
+	}
+
+	var buf bytes.Buffer
+	log := logger.New(&buf, logger.LogfmtFormat(), logger.Info)
+	stats := statter.New(l2met.New(log, ""), time.Hour)
+	w.SetStatter(stats)
+	close(bw.unblock)
+	require.NoError(t, w.Close())
+
+	require.NoError(t, stats.Close())
+	assert.Contains(t, buf.String(), fmt.Sprintf("count#log.dropped=%d", w.Dropped()))
+}
+
+func TestAsyncWriter_WriteAfterClose(t *testing.T) {
+	w := cmd.NewAsyncWriter(&bytes.Buffer{}, 1, true)
+	require.NoError(t, w.Close())
+
+	_, err := w.Write([]byte("line\n"))
+
+	assert.ErrorIs(t, err, cmd.ErrWriterClosed)
+	assert.NoError(t, w.Close())
+}
+
+type blockingWriter struct {
+	unblock chan struct{}
+
+	mu sync.Mutex
+	n  int
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	<-w.unblock
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.n++
+	return len(p), nil
+}
+
+func (w *blockingWriter) lines() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.n
+}
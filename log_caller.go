@@ -0,0 +1,162 @@
+package cmd
+
+import (
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hamba/logger/v2"
+	"github.com/hamba/logger/v2/ctx"
+)
+
+// Log field keys added by the caller formatter and LogError.
+const (
+	logCallerKey = "caller"
+	logStackKey  = "stack"
+)
+
+// callerSkipPrefixes are the function name prefixes of the logging
+// packages, which are skipped when looking for the call site.
+var callerSkipPrefixes = []string{
+	"github.com/hamba/logger/v2",
+	"github.com/hamba/cmd/v3.",
+	"github.com/hamba/cmd/v3/observe.",
+	"github.com/go-logr/",
+	"log.",
+	"log/slog.",
+}
+
+// callerFormatter adds the file and line of the call site to each log line.
+type callerFormatter[B buffer] struct {
+	formatter[B]
+}
+
+func newCallerFormatter[B buffer](f formatter[B]) logger.Formatter {
+	return asFormatter[B](callerFormatter[B]{formatter: f})
+}
+
+func (f callerFormatter[B]) WriteMessage(buf B, ts time.Time, lvl logger.Level, msg string) {
+	f.formatter.WriteMessage(buf, ts, lvl, msg)
+
+	if frame, ok := caller(); ok {
+		appendCaller(f.formatter, buf, frame)
+	}
+}
+
+// callerAppender is implemented by formatters that write the
+// call site in their own fields, rather than the caller field.
+type callerAppender[B buffer] interface {
+	AppendCaller(buf B, frame runtime.Frame)
+}
+
+// appendCaller appends the call site of the frame with the formatter.
+func appendCaller[B buffer](f formatter[B], buf B, frame runtime.Frame) {
+	if ca, ok := f.(callerAppender[B]); ok {
+		ca.AppendCaller(buf, frame)
+		return
+	}
+	f.AppendKey(buf, logCallerKey)
+	f.AppendString(buf, callerString(frame))
+}
+
+// callerString returns the `dir/file:line` of the frame.
+func callerString(frame runtime.Frame) string {
+	return shortFile(frame.File) + ":" + strconv.Itoa(frame.Line)
+}
+
+// caller returns the frame of the first caller outside the logging packages.
+func caller() (runtime.Frame, bool) {
+	var pcs [32]uintptr
+	n := runtime.Callers(3, pcs[:])
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if !isLoggingFunc(frame.Function) {
+			return frame, true
+		}
+		if !more {
+			return runtime.Frame{}, false
+		}
+	}
+}
+
+func isLoggingFunc(fn string) bool {
+	for _, prefix := range callerSkipPrefixes {
+		if strings.HasPrefix(fn, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// shortFile trims the file path to its directory and file name.
+func shortFile(file string) string {
+	i := strings.LastIndexByte(file, '/')
+	if i <= 0 {
+		return file
+	}
+	if j := strings.LastIndexByte(file[:i], '/'); j >= 0 {
+		return file[j+1:]
+	}
+	return file
+}
+
+// LogError logs the message and error at the given level. Lines at
+// error level and above include a stack trace of the call site.
+func LogError(log *logger.Logger, lvl logger.Level, msg string, err error, fields ...logger.Field) {
+	fields = slices.Clip(fields)
+	if err != nil {
+		fields = append(fields, ctx.Err(err))
+	}
+	if lvl <= logger.Error && lvl > logger.Disabled {
+		fields = append(fields, ctx.Str(logStackKey, stack(3)))
+	}
+
+	switch lvl {
+	case logger.Crit:
+		log.Crit(msg, fields...)
+	case logger.Error:
+		log.Error(msg, fields...)
+	case logger.Warn:
+		log.Warn(msg, fields...)
+	case logger.Info:
+		log.Info(msg, fields...)
+	case logger.Debug:
+		log.Debug(msg, fields...)
+	case logger.Trace:
+		log.Trace(msg, fields...)
+	}
+}
+
+// stack returns the formatted stack trace, skipping the given number of frames.
+// Each frame is formatted as the function followed by its file and line.
+func stack(skip int) string {
+	var pcs [64]uintptr
+	n := runtime.Callers(skip, pcs[:])
+	frames := runtime.CallersFrames(pcs[:n])
+
+	var sb strings.Builder
+	for {
+		frame, more := frames.Next()
+		if strings.HasPrefix(frame.Function, "runtime.") {
+			if !more {
+				break
+			}
+			continue
+		}
+		if sb.Len() > 0 {
+			sb.WriteByte('\n')
+		}
+		sb.WriteString(frame.Function)
+		sb.WriteString("\n\t")
+		sb.WriteString(frame.File)
+		sb.WriteByte(':')
+		sb.WriteString(strconv.Itoa(frame.Line))
+		if !more {
+			break
+		}
+	}
+	return sb.String()
+}
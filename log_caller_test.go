@@ -0,0 +1,94 @@
+package cmd_test
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/hamba/cmd/v3"
+	"github.com/hamba/logger/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewLogger_Caller(t *testing.T) {
+	var buf bytes.Buffer
+	log := newTestLogger(t, cmd.NewLoggerWithOptions, &buf, "--log.caller", "--log.ctx=a=b")
+
+	log.Info("test")
+
+	assert.Regexp(t, `^lvl=info msg=test caller=[^ ]+/log_caller_test\.go:\d+ a=b\n$`, buf.String())
+}
+
+func TestNewLogger_CallerSlog(t *testing.T) {
+	var buf bytes.Buffer
+	log := newTestLogger(t, cmd.NewLoggerWithOptions, &buf, "--log.caller", "--log.format=json")
+
+	slog.New(cmd.NewSlogHandler(log, nil)).Info("test")
+
+	assert.Regexp(t, `^\{"lvl":"info","msg":"test","caller":"[^"]+/log_caller_test\.go:\d+"\}\n$`, buf.String())
+}
+
+func TestNewLogger_CallerECS(t *testing.T) {
+	var buf bytes.Buffer
+	log := newTestLogger(t, cmd.NewLoggerWithOptions, &buf, "--log.caller", "--log.format=ecs")
+
+	log.Info("test")
+
+	want := `^\{"log.level":"info","message":"test","ecs.version":"1.6.0",` +
+		`"log.origin.function":"github.com/hamba/cmd/v3_test.TestNewLogger_CallerECS",` +
+		`"log.origin.file.name":"[^"]+/log_caller_test\.go","log.origin.file.line":\d+\}\n$`
+	assert.Regexp(t, want, buf.String())
+}
+
+func TestNewLogger_NoCaller(t *testing.T) {
+	var buf bytes.Buffer
+	log := newTestLogger(t, cmd.NewLoggerWithOptions, &buf)
+
+	log.Info("test")
+
+	assert.Equal(t, "lvl=info msg=test\n", buf.String())
+}
+
+func TestLogError(t *testing.T) {
+	tests := []struct {
+		name      string
+		lvl       logger.Level
+		wantStack bool
+	}{
+		{
+			name:      "crit",
+			lvl:       logger.Crit,
+			wantStack: true,
+		},
+		{
+			name:      "error",
+			lvl:       logger.Error,
+			wantStack: true,
+		},
+		{
+			name:      "warn",
+			lvl:       logger.Warn,
+			wantStack: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			log := newTestLogger(t, cmd.NewLoggerWithOptions, &buf, "--log.format=json", "--log.caller")
+
+			cmd.LogError(log, test.lvl, "failed", errors.New("test error"))
+
+			got := buf.String()
+			assert.Contains(t, got, `"msg":"failed"`)
+			assert.Regexp(t, `"caller":"[^"]+/log_caller_test\.go:\d+"`, got)
+			assert.Contains(t, got, `"error":"test error"`)
+			if !test.wantStack {
+				assert.NotContains(t, got, `"stack"`)
+				return
+			}
+			assert.Regexp(t, `"stack":"github.com/hamba/cmd/v3_test.TestLogError.func1\\n\\t[^"]+/log_caller_test\.go:\d+`, got)
+		})
+	}
+}
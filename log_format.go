@@ -0,0 +1,305 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"runtime"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/hamba/logger/v2"
+)
+
+// ecsVersion is the ECS version logs are formatted in.
+const ecsVersion = "1.6.0"
+
+// appendRFC3339 appends the time in RFC 3339 format, as required by the schemas.
+func appendRFC3339[B buffer](buf B, t time.Time) {
+	var b [64]byte
+	buf.WriteString(`"`)
+	buf.Write(t.UTC().AppendFormat(b[:0], time.RFC3339Nano))
+	buf.WriteString(`"`)
+}
+
+// ecsFormatter formats log lines in the Elastic Common Schema.
+type ecsFormatter[B buffer] struct {
+	formatter[B]
+}
+
+// newECSFormatter returns an ECS formatter wrapping the json formatter f.
+func newECSFormatter[B buffer](f formatter[B]) logger.Formatter {
+	return asFormatter[B](ecsFormatter[B]{formatter: f})
+}
+
+func (f ecsFormatter[B]) WriteMessage(buf B, ts time.Time, lvl logger.Level, msg string) {
+	if !ts.IsZero() {
+		buf.WriteString(`"@timestamp":`)
+		appendRFC3339(buf, ts)
+		buf.WriteString(",")
+	}
+	buf.WriteString(`"log.level":"` + levelName(lvl) + `","message":`)
+	f.AppendString(buf, msg)
+	buf.WriteString(`,"ecs.version":"` + ecsVersion + `"`)
+}
+
+func (f ecsFormatter[B]) AppendKey(buf B, key string) {
+	switch key {
+	case "error":
+		key = "error.message"
+	case logTraceIDKey:
+		key = "trace.id"
+	case logSpanIDKey:
+		key = "span.id"
+	}
+	f.formatter.AppendKey(buf, key)
+}
+
+// AppendCaller appends the call site as the log origin.
+func (f ecsFormatter[B]) AppendCaller(buf B, frame runtime.Frame) {
+	f.formatter.AppendKey(buf, "log.origin.function")
+	f.formatter.AppendString(buf, frame.Function)
+	f.formatter.AppendKey(buf, "log.origin.file.name")
+	f.formatter.AppendString(buf, shortFile(frame.File))
+	f.formatter.AppendKey(buf, "log.origin.file.line")
+	f.formatter.AppendInt(buf, int64(frame.Line))
+}
+
+// gcpTraceKey is the Google Cloud Logging trace key, as written by the json formatter.
+var gcpTraceKey = []byte(`"logging.googleapis.com/trace":`)
+
+// gcpRecordedTraceKey is the trace key followed by the key
+// frame the record formatter writes for it in a full record.
+var gcpRecordedTraceKey = append(
+	binary.AppendUvarint(append(slices.Clip(gcpTraceKey), frameMarker, frameKey), uint64(len(logTraceIDKey))),
+	logTraceIDKey...,
+)
+
+// gcpFormatter formats log lines for Google Cloud Logging.
+type gcpFormatter[B buffer] struct {
+	formatter[B]
+
+	project string
+}
+
+// newGCPFormatter returns a Google Cloud Logging formatter wrapping the json formatter f.
+// When a project is given, trace IDs are written as trace resource names of the project.
+func newGCPFormatter[B buffer](f formatter[B], project string) logger.Formatter {
+	return asFormatter[B](gcpFormatter[B]{formatter: f, project: project})
+}
+
+func (f gcpFormatter[B]) WriteMessage(buf B, ts time.Time, lvl logger.Level, msg string) {
+	if !ts.IsZero() {
+		buf.WriteString(`"timestamp":`)
+		appendRFC3339(buf, ts)
+		buf.WriteString(",")
+	}
+	buf.WriteString(`"severity":"` + gcpSeverity(lvl) + `","message":`)
+	f.AppendString(buf, msg)
+}
+
+func (f gcpFormatter[B]) AppendKey(buf B, key string) {
+	switch key {
+	case logTraceIDKey:
+		key = "logging.googleapis.com/trace"
+	case logSpanIDKey:
+		key = "logging.googleapis.com/spanId"
+	}
+	f.formatter.AppendKey(buf, key)
+}
+
+func (f gcpFormatter[B]) AppendString(buf B, s string) {
+	if f.project != "" && isTraceValue(buf.Bytes(), s) {
+		s = "projects/" + f.project + "/traces/" + s
+	}
+	f.formatter.AppendString(buf, s)
+}
+
+// isTraceValue reports if the value s about to be written to b is the trace ID,
+// the output of the current sink ending with the trace key.
+//
+// The key directly precedes the value, unless the line is fully recorded,
+// where the key and string frames are in between, or written to several
+// sinks, where the output of the other sinks is in between. Only in the
+// latter case is the line followed per sink.
+func isTraceValue(b []byte, s string) bool {
+	if bytes.HasSuffix(b, gcpTraceKey) {
+		return true
+	}
+	if n, ok := stringFrameSuffix(b, s); ok {
+		return bytes.HasSuffix(b[:len(b)-n], gcpRecordedTraceKey)
+	}
+	sink, ok := sinkFrameSuffix(b)
+	if !ok {
+		return false
+	}
+
+	var cur, pending bool
+	for i := 0; i < len(b); {
+		if b[i] == frameMarker {
+			if i+2 > len(b) {
+				// This cannot happen for frames written by the other formatters.
+				return false
+			}
+			l, n := binary.Uvarint(b[i+2:])
+			end := i + 2 + n + int(l)
+			if n <= 0 || end > len(b) {
+				// This cannot happen for frames written by the other formatters.
+				return false
+			}
+			if b[i+1] == frameSink {
+				idx, _ := binary.Uvarint(b[i+2+n : end])
+				cur = idx == sink
+			}
+			i = end
+			continue
+		}
+
+		j := bytes.IndexByte(b[i:], frameMarker)
+		if j < 0 {
+			j = len(b)
+		} else {
+			j += i
+		}
+		if cur {
+			pending = bytes.HasSuffix(b[i:j], gcpTraceKey)
+		}
+		i = j
+	}
+	return pending
+}
+
+// stringFrameSuffix returns the length of the string frame of s ending b, if any.
+func stringFrameSuffix(b []byte, s string) (int, bool) {
+	var hdr [2 + binary.MaxVarintLen64]byte
+	hdr[0], hdr[1] = frameMarker, frameString
+	n := 2 + binary.PutUvarint(hdr[2:], uint64(len(s)))
+	l := n + len(s)
+	if len(b) < l || string(b[len(b)-len(s):]) != s || !bytes.Equal(b[len(b)-l:len(b)-len(s)], hdr[:n]) {
+		return 0, false
+	}
+	return l, true
+}
+
+// sinkFrameSuffix returns the index of the sink frame ending b, if any.
+func sinkFrameSuffix(b []byte) (uint64, bool) {
+	for n := 1; n <= binary.MaxVarintLen64 && len(b) >= 3+n; n++ {
+		hdr := b[len(b)-3-n:]
+		if hdr[0] != frameMarker || hdr[1] != frameSink || int(hdr[2]) != n {
+			continue
+		}
+		idx, m := binary.Uvarint(hdr[3:])
+		return idx, m == n
+	}
+	return 0, false
+}
+
+func gcpSeverity(lvl logger.Level) string {
+	switch lvl {
+	case logger.Crit:
+		return "CRITICAL"
+	case logger.Error:
+		return "ERROR"
+	case logger.Warn:
+		return "WARNING"
+	case logger.Info:
+		return "INFO"
+	default:
+		return "DEBUG"
+	}
+}
+
+// otelAttributesStart opens the attributes of an OTel log line.
+const otelAttributesStart = `,"attributes":{`
+
+// otelJSONFormatter formats log lines in the OTel log data model,
+// with the fields as attributes.
+//
+// Every key is written with a separator, as the first attribute cannot
+// be known when the line is formatted. The separator of the first
+// attribute is removed once the line ends.
+type otelJSONFormatter[B buffer] struct {
+	formatter[B]
+}
+
+// newOTelJSONFormatter returns an OTel json formatter wrapping the json formatter f.
+func newOTelJSONFormatter[B buffer](f formatter[B]) logger.Formatter {
+	return asFormatter[B](otelJSONFormatter[B]{formatter: f})
+}
+
+func (f otelJSONFormatter[B]) WriteMessage(buf B, ts time.Time, lvl logger.Level, msg string) {
+	if !ts.IsZero() {
+		buf.WriteString(`"timestamp":`)
+		appendRFC3339(buf, ts)
+		buf.WriteString(",")
+	}
+	sev := otelSeverity(lvl)
+	buf.WriteString(`"severity_text":"` + sev.String() + `","severity_number":` + strconv.Itoa(int(sev)) + `,"body":`)
+	f.AppendString(buf, msg)
+	buf.WriteString(otelAttributesStart)
+}
+
+func (f otelJSONFormatter[B]) AppendEndMarker(buf B) {
+	b := removeAttributesSep(buf.Bytes())
+	buf.Reset()
+	buf.Write(b)
+	buf.WriteString("}}")
+}
+
+// removeAttributesSep removes the separator of the first attribute in b.
+//
+// With multiple sinks, the line holds the output of each sink in segments
+// started by sink frames, so the separator is removed per sink.
+func removeAttributesSep(b []byte) []byte {
+	var (
+		out     = b[:0]
+		sink    int
+		pending = map[int]bool{}
+	)
+	for i := 0; i < len(b); {
+		if b[i] == frameMarker {
+			if i+2 > len(b) {
+				// This cannot happen for frames written by the other formatters.
+				return append(out, b[i:]...)
+			}
+			l, n := binary.Uvarint(b[i+2:])
+			end := i + 2 + n + int(l)
+			if n <= 0 || end > len(b) {
+				// This cannot happen for frames written by the other formatters.
+				return append(out, b[i:]...)
+			}
+			if b[i+1] == frameSink {
+				idx, _ := binary.Uvarint(b[i+2+n : end])
+				sink = int(idx) + 1
+			}
+			out = append(out, b[i:end]...)
+			i = end
+			continue
+		}
+
+		j := bytes.IndexByte(b[i:], frameMarker)
+		if j < 0 {
+			j = len(b)
+		} else {
+			j += i
+		}
+		run := b[i:j]
+		i = j
+
+		if pending[sink] {
+			delete(pending, sink)
+			run = bytes.TrimPrefix(run, []byte(","))
+		}
+		if k := bytes.Index(run, []byte(otelAttributesStart)); k >= 0 {
+			k += len(otelAttributesStart)
+			if k == len(run) {
+				pending[sink] = true
+			} else if run[k] == ',' {
+				out = append(out, run[:k]...)
+				run = run[k+1:]
+			}
+		}
+		out = append(out, run...)
+	}
+	return out
+}
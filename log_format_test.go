@@ -0,0 +1,215 @@
+package cmd_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hamba/cmd/v3"
+	lctx "github.com/hamba/logger/v2/ctx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v3"
+	otellog "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/log/noop"
+)
+
+func TestNewLogger_Formats(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		want   string
+	}{
+		{
+			name:   "ecs",
+			format: "ecs",
+			want: `{"log.level":"warn","message":"test \"message\"","ecs.version":"1.6.0","env":"prod",` +
+				`"error.message":"test error","trace.id":"abc","span.id":"def","n":[1,2]}` + "\n",
+		},
+		{
+			name:   "gcp",
+			format: "gcp",
+			want: `{"severity":"WARNING","message":"test \"message\"","env":"prod","error":"test error",` +
+				`"logging.googleapis.com/trace":"abc","logging.googleapis.com/spanId":"def","n":[1,2]}` + "\n",
+		},
+		{
+			name:   "otel json",
+			format: "otel-json",
+			want: `{"severity_text":"WARN","severity_number":13,"body":"test \"message\"","attributes":{"env":"prod",` +
+				`"error":"test error","trace_id":"abc","span_id":"def","n":[1,2]}}` + "\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			c := &cli.Command{
+				Flags: cmd.LogFlags,
+				Action: func(_ context.Context, c *cli.Command) error {
+					log, err := cmd.NewLoggerWithOptions(c, &cmd.LoggerOptions{Writer: &buf})
+					if err != nil {
+						return err
+					}
+
+					log.Warn(`test "message"`,
+						lctx.Err(errors.New("test error")),
+						lctx.Str("trace_id", "abc"),
+						lctx.Str("span_id", "def"),
+						lctx.Ints("n", []int{1, 2}),
+					)
+					return nil
+				},
+			}
+
+			err := c.Run(t.Context(), []string{"test", "--log.format=" + test.format, "--log.ctx=env=prod"})
+
+			require.NoError(t, err)
+			assert.Equal(t, test.want, buf.String())
+			assert.True(t, json.Valid(buf.Bytes()))
+		})
+	}
+}
+
+func TestNewLogger_FormatGCPProject(t *testing.T) {
+	dir := t.TempDir()
+	gcpFile := filepath.Join(dir, "gcp.json")
+	jsonFile := filepath.Join(dir, "app.json")
+
+	tests := []struct {
+		name     string
+		args     []string
+		provider otellog.LoggerProvider
+		wantGCP  string
+		wantJSON string
+	}{
+		{
+			name: "file",
+			args: []string{"--log.format=gcp", "--log.file=" + gcpFile},
+			wantGCP: `{"severity":"INFO","message":"test","logging.googleapis.com/trace":"projects/my-project/traces/abc",` +
+				`"logging.googleapis.com/spanId":"def","a":"abc"}` + "\n",
+		},
+		{
+			name:     "recorded",
+			args:     []string{"--log.format=gcp", "--log.file=" + gcpFile},
+			provider: noop.NewLoggerProvider(),
+			wantGCP: `{"severity":"INFO","message":"test","logging.googleapis.com/trace":"projects/my-project/traces/abc",` +
+				`"logging.googleapis.com/spanId":"def","a":"abc"}` + "\n",
+		},
+		{
+			name: "sinks",
+			args: []string{"--log.sink=file://" + jsonFile + "?format=json", "--log.sink=file://" + gcpFile + "?format=gcp"},
+			wantGCP: `{"severity":"INFO","message":"test","logging.googleapis.com/trace":"projects/my-project/traces/abc",` +
+				`"logging.googleapis.com/spanId":"def","a":"abc"}` + "\n",
+			wantJSON: `{"lvl":"info","msg":"test","trace_id":"abc","span_id":"def","a":"abc"}` + "\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_ = os.Remove(gcpFile)
+			_ = os.Remove(jsonFile)
+
+			c := &cli.Command{
+				Flags: cmd.LogFlags,
+				Action: func(_ context.Context, c *cli.Command) error {
+					w, err := cmd.NewLogWriter(c)
+					if err != nil {
+						return err
+					}
+					defer func() { _ = w.Close() }()
+
+					log, err := cmd.NewLoggerWithOptions(c, &cmd.LoggerOptions{Writer: w, Provider: test.provider})
+					if err != nil {
+						return err
+					}
+
+					log.Info("test", lctx.Str("trace_id", "abc"), lctx.Str("span_id", "def"), lctx.Str("a", "abc"))
+					return nil
+				},
+			}
+
+			err := c.Run(t.Context(), append([]string{"test", "--log.gcp-project=my-project"}, test.args...))
+
+			require.NoError(t, err)
+			assert.Equal(t, test.wantGCP, readFile(t, gcpFile))
+			assert.Equal(t, test.wantJSON, readFile(t, jsonFile))
+		})
+	}
+}
+
+func TestNewLogger_FormatsTimestamp(t *testing.T) {
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{format: "ecs", want: `^{"@timestamp":"\d{4}-\d\d-\d\dT[\d:.]+Z","log.level":"info","message":"test","ecs.version":"1.6.0"}`},
+		{format: "gcp", want: `^{"timestamp":"\d{4}-\d\d-\d\dT[\d:.]+Z","severity":"INFO","message":"test"}`},
+		{format: "otel-json", want: `^{"timestamp":"\d{4}-\d\d-\d\dT[\d:.]+Z","severity_text":"INFO","severity_number":9,"body":"test","attributes":{}}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.format, func(t *testing.T) {
+			var buf bytes.Buffer
+			c := &cli.Command{
+				Flags: cmd.LogFlags,
+				Action: func(_ context.Context, c *cli.Command) error {
+					log, err := cmd.NewLoggerWithOptions(c, &cmd.LoggerOptions{Writer: &buf})
+					if err != nil {
+						return err
+					}
+					defer log.WithTimestamp()()
+
+					log.Info("test")
+					return nil
+				},
+			}
+
+			err := c.Run(t.Context(), []string{"test", "--log.format=" + test.format})
+
+			require.NoError(t, err)
+			assert.Regexp(t, test.want, buf.String())
+		})
+	}
+}
+
+func TestNewLogger_FormatsSinks(t *testing.T) {
+	dir := t.TempDir()
+	file1 := filepath.Join(dir, "app1.json")
+	file2 := filepath.Join(dir, "app2.json")
+
+	c := &cli.Command{
+		Flags: cmd.LogFlags,
+		Action: func(_ context.Context, c *cli.Command) error {
+			w, err := cmd.NewLogWriter(c)
+			if err != nil {
+				return err
+			}
+			defer func() { _ = w.Close() }()
+
+			log, err := cmd.NewLoggerWithOptions(c, &cmd.LoggerOptions{Writer: w})
+			if err != nil {
+				return err
+			}
+
+			log.With(lctx.Str("a", "b")).Info("test", lctx.Int("n", 1))
+			return nil
+		},
+	}
+
+	err := c.Run(t.Context(), []string{
+		"test",
+		"--log.level=info,db=warn",
+		"--log.redact=a",
+		"--log.sink=file://" + file1 + "?format=otel-json",
+		"--log.sink=file://" + file2 + "?format=otel-json",
+	})
+
+	require.NoError(t, err)
+	want := `{"severity_text":"INFO","severity_number":9,"body":"test","attributes":{"a":"[REDACTED]","n":1}}` + "\n"
+	assert.Equal(t, want, readFile(t, file1))
+	assert.Equal(t, want, readFile(t, file2))
+}
@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"time"
+
+	"github.com/hamba/logger/v2"
+)
+
+// buffer is the buffer log lines are formatted into.
+//
+// The logger does not export its buffer type, so formatters are wrapped
+// generically, letting the compiler infer the buffer from the wrapped formatter.
+type buffer interface {
+	WriteString(s string)
+	Write(bs []byte)
+	Bytes() []byte
+	Len() int
+	Reset()
+}
+
+// formatter mirrors logger.Formatter over the buffer type B.
+type formatter[B buffer] interface {
+	WriteMessage(buf B, ts time.Time, lvl logger.Level, msg string)
+	AppendBeginMarker(buf B)
+	AppendEndMarker(buf B)
+	AppendLineBreak(buf B)
+	AppendArrayStart(buf B)
+	AppendArraySep(buf B)
+	AppendArrayEnd(buf B)
+	AppendKey(buf B, key string)
+	AppendString(buf B, s string)
+	AppendBool(buf B, b bool)
+	AppendInt(buf B, i int64)
+	AppendUint(buf B, i uint64)
+	AppendFloat(buf B, f float64)
+	AppendTime(buf B, t time.Time)
+	AppendDuration(buf B, d time.Duration)
+	AppendInterface(buf B, v any)
+}
+
+// asFormatter returns f as a logger formatter.
+//
+// This can only fail if B is not the logger buffer type, which the
+// compiler guarantees when B is inferred from a logger formatter.
+func asFormatter[B buffer](f formatter[B]) logger.Formatter {
+	return any(f).(logger.Formatter)
+}
@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"encoding/binary"
+	"fmt"
+	"maps"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/hamba/logger/v2"
+)
+
+// journaldSocket is the journald native protocol socket.
+const journaldSocket = "/run/systemd/journal/socket"
+
+// journaldWriter writes log lines to journald using the native protocol,
+// sending the log context as journal fields.
+type journaldWriter struct {
+	conn  *redialConn
+	hooks closeHooks
+
+	fields []byte
+}
+
+// newJournaldWriter returns a journald writer for the given target,
+// either `journald://` or `journald:///path/to/socket`.
+func newJournaldWriter(target, app string, tags map[string]string) (*journaldWriter, error) {
+	path, err := parseJournaldTarget(target)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := dialRedial("unixgram", path)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to journald: %w", err)
+	}
+
+	var fields []byte
+	if app != "" {
+		fields = appendJournaldField(fields, "SYSLOG_IDENTIFIER", app)
+	}
+	for _, k := range slices.Sorted(maps.Keys(tags)) {
+		fields = appendJournaldField(fields, journaldFieldName(k), tags[k])
+	}
+	return &journaldWriter{conn: conn, fields: fields}, nil
+}
+
+// parseJournaldTarget returns the socket path of the journald target.
+func parseJournaldTarget(target string) (string, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return "", fmt.Errorf("parsing log output: %w", err)
+	}
+	if u.Host != "" {
+		return "", fmt.Errorf("unsupported journald address %q", target)
+	}
+	if u.Path == "" {
+		return journaldSocket, nil
+	}
+	return u.Path, nil
+}
+
+// Write writes the line at info level.
+func (w *journaldWriter) Write(p []byte) (int, error) {
+	return w.writeLevel(logger.Info, p)
+}
+
+func (w *journaldWriter) writeLevel(lvl logger.Level, p []byte) (int, error) {
+	msg := make([]byte, 0, 64+len(w.fields)+len(p))
+	msg = append(msg, "PRIORITY="...)
+	msg = strconv.AppendInt(msg, int64(syslogSeverity(lvl)), 10)
+	msg = append(msg, '\n')
+	msg = appendJournaldField(msg, "MESSAGE", string(trimNewline(p)))
+	msg = append(msg, w.fields...)
+
+	if _, err := w.conn.Write(msg); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (w *journaldWriter) closeHooks() *closeHooks { return &w.hooks }
+
+// Close closes the journald connection.
+func (w *journaldWriter) Close() error {
+	w.hooks.run()
+	return w.conn.Close()
+}
+
+// appendJournaldField appends the field in the native protocol format,
+// using the binary format for values containing a newline.
+func appendJournaldField(b []byte, name, val string) []byte {
+	b = append(b, name...)
+	if !strings.Contains(val, "\n") {
+		b = append(b, '=')
+		b = append(b, val...)
+		return append(b, '\n')
+	}
+
+	b = append(b, '\n')
+	b = binary.LittleEndian.AppendUint64(b, uint64(len(val)))
+	b = append(b, val...)
+	return append(b, '\n')
+}
+
+// journaldFieldName returns the key as a journal field name, which must
+// consist of uppercase letters, digits and underscores, not starting with
+// an underscore or digit.
+func journaldFieldName(key string) string {
+	name := make([]byte, 0, len(key)+1)
+	for i := range len(key) {
+		c := key[i]
+		switch {
+		case c >= 'a' && c <= 'z':
+			c -= 'a' - 'A'
+		case c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		default:
+			c = '_'
+		}
+		name = append(name, c)
+	}
+	if len(name) == 0 || name[0] == '_' || (name[0] >= '0' && name[0] <= '9') {
+		name = append([]byte("X"), name...)
+	}
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return string(name)
+}
@@ -0,0 +1,84 @@
+package cmd_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hamba/cmd/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v3"
+)
+
+func TestNewLogger_Journald(t *testing.T) {
+	conn := listenUnixgram(t)
+
+	c := &cli.Command{
+		Name:  "app",
+		Flags: cmd.LogFlags,
+		Action: func(_ context.Context, c *cli.Command) error {
+			w, err := cmd.NewLogWriter(c)
+			if err != nil {
+				return err
+			}
+			defer func() { _ = w.Close() }()
+
+			log, err := cmd.NewLoggerWithOptions(c, &cmd.LoggerOptions{Writer: w})
+			if err != nil {
+				return err
+			}
+
+			log.Warn("test\nmessage")
+			return nil
+		},
+	}
+
+	args := []string{"app", "--log.output=journald://" + conn.LocalAddr().String(), "--log.format=json", "--log.ctx=env=prod", "--log.ctx=req.id=1"}
+	err := c.Run(t.Context(), args)
+
+	require.NoError(t, err)
+	got := readDatagram(t, conn)
+	// The ctx fields are in map order, so they are compared as sets.
+	fields := strings.Split(strings.TrimSuffix(got, "\n"), "\n")
+	var msg string
+	for i, field := range fields {
+		if m, ok := strings.CutPrefix(field, "MESSAGE="); ok {
+			msg = m
+			fields = append(fields[:i], fields[i+1:]...)
+			break
+		}
+	}
+	assert.ElementsMatch(t, []string{"PRIORITY=4", "SYSLOG_IDENTIFIER=app", "ENV=prod", "REQ_ID=1"}, fields)
+	assert.JSONEq(t, `{"lvl":"warn","msg":"test\nmessage","env":"prod","req.id":"1"}`, msg)
+}
+
+func TestNewLogger_JournaldAsync(t *testing.T) {
+	conn := listenUnixgram(t)
+
+	c := &cli.Command{
+		Name:  "app",
+		Flags: cmd.LogFlags,
+		Action: func(_ context.Context, c *cli.Command) error {
+			w, err := cmd.NewLogWriter(c)
+			if err != nil {
+				return err
+			}
+			defer func() { _ = w.Close() }()
+
+			log, err := cmd.NewLoggerWithOptions(c, &cmd.LoggerOptions{Writer: w})
+			if err != nil {
+				return err
+			}
+
+			log.Error("test message")
+			return nil
+		},
+	}
+
+	err := c.Run(t.Context(), []string{"app", "--log.output=journald://" + conn.LocalAddr().String(), "--log.async"})
+
+	require.NoError(t, err)
+	got := readDatagram(t, conn)
+	assert.Equal(t, "PRIORITY=3\nMESSAGE=lvl=eror msg=\"test message\"\nSYSLOG_IDENTIFIER=app\n", got)
+}
@@ -0,0 +1,334 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hamba/logger/v2"
+	"github.com/hamba/logger/v2/ctx"
+)
+
+// LogLevel is a log level that can be changed at runtime.
+//
+// Component levels override the level for lines logged with
+// a `component` field, and are not affected by level changes.
+//
+// LogLevel implements http.Handler, returning the current level on GET
+// and setting the level on PUT, both using the body `{"level":"info"}`.
+type LogLevel struct {
+	lvl        atomic.Int64
+	components map[string]logger.Level
+	log        *logger.Logger
+
+	// mu serializes level changes, which read the level before setting it.
+	mu sync.Mutex
+}
+
+func newLogLevel(lvl logger.Level, components map[string]logger.Level) *LogLevel {
+	l := &LogLevel{components: components}
+	l.lvl.Store(int64(lvl))
+	return l
+}
+
+// parseLogLevel parses a log level with optional component overrides,
+// in the format `info,db=debug,http=warn`.
+func parseLogLevel(str string) (logger.Level, map[string]logger.Level, error) {
+	var (
+		lvl        = logger.Info
+		hasLvl     bool
+		components map[string]logger.Level
+	)
+	for part := range strings.SplitSeq(str, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		name, lvlStr, ok := strings.Cut(part, "=")
+		if !ok {
+			if hasLvl {
+				return 0, nil, fmt.Errorf("multiple log levels specified in %q", str)
+			}
+			l, err := logger.LevelFromString(part)
+			if err != nil {
+				return 0, nil, err
+			}
+			lvl, hasLvl = l, true
+			continue
+		}
+
+		if name == "" {
+			return 0, nil, fmt.Errorf("missing component name in %q", part)
+		}
+		l, err := logger.LevelFromString(lvlStr)
+		if err != nil {
+			return 0, nil, fmt.Errorf("component %q: %w", name, err)
+		}
+		if components == nil {
+			components = map[string]logger.Level{}
+		}
+		components[name] = l
+	}
+	return lvl, components, nil
+}
+
+// Level returns the current log level.
+func (l *LogLevel) Level() logger.Level {
+	return logger.Level(l.lvl.Load())
+}
+
+// componentLevel returns the level of the given component,
+// falling back to the log level.
+func (l *LogLevel) componentLevel(component []byte) logger.Level {
+	if lvl, ok := l.components[string(component)]; ok {
+		return lvl
+	}
+	return l.Level()
+}
+
+// Enabled reports whether lines at the given level could be logged,
+// either at the log level or any of the component levels.
+func (l *LogLevel) Enabled(lvl logger.Level) bool {
+	if lvl <= l.Level() {
+		return true
+	}
+	for _, compLvl := range l.components {
+		if lvl <= compLvl {
+			return true
+		}
+	}
+	return false
+}
+
+// maxLevel returns the most verbose of the log level and the component levels.
+func (l *LogLevel) maxLevel() logger.Level {
+	lvl := l.Level()
+	for _, compLvl := range l.components {
+		lvl = max(lvl, compLvl)
+	}
+	return lvl
+}
+
+// SetLevel sets the log level.
+//
+// The change is logged at info level while either the
+// previous or the new level allows it.
+func (l *LogLevel) SetLevel(lvl logger.Level) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.setLevel(lvl)
+}
+
+func (l *LogLevel) setLevel(lvl logger.Level) {
+	old := l.Level()
+	if old == lvl {
+		return
+	}
+
+	fields := []logger.Field{ctx.Str("from", levelName(old)), ctx.Str("to", levelName(lvl))}
+	if lvl < old {
+		l.log.Info("Log level changed", fields...)
+		l.lvl.Store(int64(lvl))
+		return
+	}
+	l.lvl.Store(int64(lvl))
+	l.log.Info("Log level changed", fields...)
+}
+
+// Increase increases the verbosity of the log level by a single step.
+func (l *LogLevel) Increase() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if lvl := l.Level(); lvl < logger.Trace {
+		l.setLevel(lvl + 1)
+	}
+}
+
+// Decrease decreases the verbosity of the log level by a single step.
+func (l *LogLevel) Decrease() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if lvl := l.Level(); lvl > logger.Crit {
+		l.setLevel(lvl - 1)
+	}
+}
+
+type logLevelPayload struct {
+	Level string `json:"level"`
+}
+
+// ServeHTTP serves the log level over http.
+func (l *LogLevel) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var p logLevelPayload
+		if err := json.NewDecoder(req.Body).Decode(&p); err != nil {
+			http.Error(rw, "invalid request body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		lvl, err := logger.LevelFromString(p.Level)
+		if err != nil {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+		l.SetLevel(lvl)
+	default:
+		rw.Header().Set("Allow", "GET, PUT")
+		http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(rw).Encode(logLevelPayload{Level: levelName(l.Level())})
+}
+
+// levelName returns the name of the level as accepted by logger.LevelFromString.
+func levelName(lvl logger.Level) string {
+	switch lvl {
+	case logger.Trace:
+		return "trace"
+	case logger.Debug:
+		return "debug"
+	case logger.Info:
+		return "info"
+	case logger.Warn:
+		return "warn"
+	case logger.Error:
+		return "error"
+	case logger.Crit:
+		return "crit"
+	default:
+		return lvl.String()
+	}
+}
+
+// skipFrame replaces the lines skipped by the level gate formatter.
+var skipFrame = []byte{frameMarker, frameSkip, 0}
+
+// isSkipped reports whether the line was skipped by the level gate formatter.
+func isSkipped(p []byte) bool {
+	return len(p) >= 2 && p[0] == frameMarker && p[1] == frameSkip
+}
+
+// levelGateFormatter skips formatting the lines disabled by the dynamic level.
+// The logger level is then Trace, so that the level can be made more verbose,
+// and the lines would otherwise be fully formatted before being discarded.
+//
+// A skipped line is replaced by a skip frame, which the record writer discards.
+type levelGateFormatter[B buffer] struct {
+	formatter[B]
+
+	lvl *LogLevel
+}
+
+func newLevelGateFormatter[B buffer](f formatter[B], lvl *LogLevel) logger.Formatter {
+	return asFormatter[B](levelGateFormatter[B]{formatter: f, lvl: lvl})
+}
+
+func (f levelGateFormatter[B]) WriteMessage(buf B, ts time.Time, lvl logger.Level, msg string) {
+	if !f.lvl.Enabled(lvl) {
+		buf.Reset()
+		buf.Write(skipFrame)
+		return
+	}
+	f.formatter.WriteMessage(buf, ts, lvl, msg)
+}
+
+func (f levelGateFormatter[B]) AppendBeginMarker(buf B) {
+	if !isSkipped(buf.Bytes()) {
+		f.formatter.AppendBeginMarker(buf)
+	}
+}
+
+func (f levelGateFormatter[B]) AppendEndMarker(buf B) {
+	if !isSkipped(buf.Bytes()) {
+		f.formatter.AppendEndMarker(buf)
+	}
+}
+
+func (f levelGateFormatter[B]) AppendLineBreak(buf B) {
+	if !isSkipped(buf.Bytes()) {
+		f.formatter.AppendLineBreak(buf)
+	}
+}
+
+func (f levelGateFormatter[B]) AppendArrayStart(buf B) {
+	if !isSkipped(buf.Bytes()) {
+		f.formatter.AppendArrayStart(buf)
+	}
+}
+
+func (f levelGateFormatter[B]) AppendArraySep(buf B) {
+	if !isSkipped(buf.Bytes()) {
+		f.formatter.AppendArraySep(buf)
+	}
+}
+
+func (f levelGateFormatter[B]) AppendArrayEnd(buf B) {
+	if !isSkipped(buf.Bytes()) {
+		f.formatter.AppendArrayEnd(buf)
+	}
+}
+
+func (f levelGateFormatter[B]) AppendKey(buf B, key string) {
+	if !isSkipped(buf.Bytes()) {
+		f.formatter.AppendKey(buf, key)
+	}
+}
+
+func (f levelGateFormatter[B]) AppendString(buf B, s string) {
+	if !isSkipped(buf.Bytes()) {
+		f.formatter.AppendString(buf, s)
+	}
+}
+
+func (f levelGateFormatter[B]) AppendBool(buf B, b bool) {
+	if !isSkipped(buf.Bytes()) {
+		f.formatter.AppendBool(buf, b)
+	}
+}
+
+func (f levelGateFormatter[B]) AppendInt(buf B, i int64) {
+	if !isSkipped(buf.Bytes()) {
+		f.formatter.AppendInt(buf, i)
+	}
+}
+
+func (f levelGateFormatter[B]) AppendUint(buf B, i uint64) {
+	if !isSkipped(buf.Bytes()) {
+		f.formatter.AppendUint(buf, i)
+	}
+}
+
+func (f levelGateFormatter[B]) AppendFloat(buf B, v float64) {
+	if !isSkipped(buf.Bytes()) {
+		f.formatter.AppendFloat(buf, v)
+	}
+}
+
+func (f levelGateFormatter[B]) AppendTime(buf B, t time.Time) {
+	if !isSkipped(buf.Bytes()) {
+		f.formatter.AppendTime(buf, t)
+	}
+}
+
+func (f levelGateFormatter[B]) AppendDuration(buf B, d time.Duration) {
+	if !isSkipped(buf.Bytes()) {
+		f.formatter.AppendDuration(buf, d)
+	}
+}
+
+func (f levelGateFormatter[B]) AppendInterface(buf B, v any) {
+	if !isSkipped(buf.Bytes()) {
+		f.formatter.AppendInterface(buf, v)
+	}
+}
@@ -0,0 +1,38 @@
+//go:build !windows
+
+package cmd
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// HandleSignals increases the log level verbosity on SIGUSR1 and
+// decreases it on SIGUSR2 until the returned function is called.
+func (l *LogLevel) HandleSignals() (stop func()) {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGUSR1, syscall.SIGUSR2)
+
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case sig := <-sigCh:
+				switch sig {
+				case syscall.SIGUSR1:
+					l.Increase()
+				case syscall.SIGUSR2:
+					l.Decrease()
+				}
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(sigCh)
+		close(done)
+	}
+}
@@ -0,0 +1,28 @@
+//go:build !windows
+
+package cmd_test
+
+import (
+	"bytes"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/hamba/logger/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogLevel_HandleSignals(t *testing.T) {
+	var buf bytes.Buffer
+	lvl := newTestLogger(t, newLevelLogger, &buf, "--log.level=info").lvl
+
+	stop := lvl.HandleSignals()
+	t.Cleanup(stop)
+
+	require.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGUSR1))
+	assert.Eventually(t, func() bool { return lvl.Level() == logger.Debug }, time.Second, 10*time.Millisecond)
+
+	require.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGUSR2))
+	assert.Eventually(t, func() bool { return lvl.Level() == logger.Info }, time.Second, 10*time.Millisecond)
+}
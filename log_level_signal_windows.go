@@ -0,0 +1,9 @@
+//go:build windows
+
+package cmd
+
+// HandleSignals does nothing, as SIGUSR1 and SIGUSR2
+// are not supported on windows.
+func (l *LogLevel) HandleSignals() (stop func()) {
+	return func() {}
+}
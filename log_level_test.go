@@ -0,0 +1,264 @@
+package cmd_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hamba/cmd/v3"
+	"github.com/hamba/logger/v2"
+	"github.com/hamba/logger/v2/ctx"
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli/v3"
+)
+
+func TestNewLoggerWithLevel(t *testing.T) {
+	var buf bytes.Buffer
+	log := newTestLogger(t, newLevelLogger, &buf, "--log.level=info", "--log.format=logfmt", "--log.ctx=a=b")
+
+	log.Debug("dropped")
+	log.Info("kept")
+	log.lvl.SetLevel(logger.Debug)
+	log.Debug("now kept")
+	log.lvl.SetLevel(logger.Error)
+	log.Info("dropped again")
+
+	want := "lvl=info msg=kept a=b\n" +
+		"lvl=info msg=\"Log level changed\" a=b from=info to=debug\n" +
+		"lvl=dbug msg=\"now kept\" a=b\n" +
+		"lvl=info msg=\"Log level changed\" a=b from=debug to=error\n"
+	assert.Equal(t, want, buf.String())
+	assert.Equal(t, logger.Error, log.lvl.Level())
+}
+
+func TestNewLoggerWithLevel_WithComponents(t *testing.T) {
+	var buf bytes.Buffer
+	log := newTestLogger(t, newLevelLogger, &buf, "--log.level=info,db=debug,http=error", "--log.format=logfmt")
+
+	dbLog := log.With(ctx.Str("component", "db"))
+	httpLog := log.With(ctx.Str("component", "http"))
+
+	log.Debug("dropped")
+	dbLog.Debug("db kept", ctx.Str("a", "b"))
+	dbLog.Trace("db dropped")
+	httpLog.Warn("http dropped")
+	httpLog.Error("http kept")
+	log.With(ctx.Str("component", "other")).Info("other kept")
+	log.lvl.SetLevel(logger.Error)
+	dbLog.Debug("db still kept")
+
+	want := "lvl=dbug msg=\"db kept\" component=db a=b\n" +
+		"lvl=eror msg=\"http kept\" component=http\n" +
+		"lvl=info msg=\"other kept\" component=other\n" +
+		"lvl=info msg=\"Log level changed\" from=info to=error\n" +
+		"lvl=dbug msg=\"db still kept\" component=db\n"
+	assert.Equal(t, want, buf.String())
+}
+
+func TestNewLoggerWithLevel_WithComponentsJSONFormat(t *testing.T) {
+	var buf bytes.Buffer
+	log := newTestLogger(t, newLevelLogger, &buf, "--log.level=warn,db=debug", "--log.format=json")
+
+	log.With(ctx.Str("component", "db")).Debug("kept", ctx.Str("component", "db"))
+
+	assert.Equal(t, `{"lvl":"dbug","msg":"kept","component":"db","component":"db"}`+"\n", buf.String())
+}
+
+func TestNewLoggerWithLevel_WithJSONFormat(t *testing.T) {
+	var buf bytes.Buffer
+	log := newTestLogger(t, newLevelLogger, &buf, "--log.level=info", "--log.format=json")
+
+	log.Info("kept")
+
+	assert.Equal(t, `{"lvl":"info","msg":"kept"}`+"\n", buf.String())
+}
+
+func TestNewLoggerWithLevel_ControlCharInKey(t *testing.T) {
+	var buf bytes.Buffer
+	log := newTestLogger(t, newLevelLogger, &buf, "--log.level=info", "--log.format=logfmt")
+
+	log.Info("kept", ctx.Str("a\x00b", "c"), ctx.Str("d", "e"))
+
+	assert.Equal(t, "lvl=info msg=kept a_b=c d=e\n", buf.String())
+}
+
+func TestNewLoggerWithLevel_SkipsFormatting(t *testing.T) {
+	var buf bytes.Buffer
+	log := newTestLogger(t, newLevelLogger, &buf, "--log.level=info", "--log.format=logfmt")
+
+	var v countingStringer
+	log.Debug("dropped", ctx.Interface("v", &v))
+	log.lvl.SetLevel(logger.Debug)
+	log.Debug("kept", ctx.Interface("v", &v))
+
+	assert.Equal(t, 1, int(v))
+	assert.Contains(t, buf.String(), "lvl=dbug msg=kept v=1\n")
+}
+
+// countingStringer counts the times it is formatted.
+type countingStringer int
+
+func (s *countingStringer) String() string {
+	*s++
+	return strconv.Itoa(int(*s))
+}
+
+func TestLogLevel_ConcurrentChanges(t *testing.T) {
+	var buf bytes.Buffer
+	lvl := newTestLogger(t, newLevelLogger, &buf, "--log.level=crit").lvl
+
+	// Each change reads the level before setting it, so no
+	// change may be lost when they happen concurrently.
+	var wg sync.WaitGroup
+	for range int(logger.Trace - logger.Crit) {
+		wg.Go(lvl.Increase)
+	}
+	wg.Wait()
+	assert.Equal(t, logger.Trace, lvl.Level())
+
+	for range int(logger.Trace - logger.Crit) {
+		wg.Go(lvl.Decrease)
+	}
+	wg.Wait()
+	assert.Equal(t, logger.Crit, lvl.Level())
+}
+
+func TestNewLoggerWithLevel_InvalidLevel(t *testing.T) {
+	tests := []struct {
+		name string
+		lvl  string
+	}{
+		{
+			name: "invalid level",
+			lvl:  "invalid",
+		},
+		{
+			name: "multiple levels",
+			lvl:  "info,debug",
+		},
+		{
+			name: "invalid component level",
+			lvl:  "info,db=invalid",
+		},
+		{
+			name: "missing component name",
+			lvl:  "info,=debug",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := &cli.Command{
+				Flags: cmd.LogFlags,
+				Action: func(_ context.Context, c *cli.Command) error {
+					_, _, err := cmd.NewLoggerWithLevel(c, &cmd.LoggerOptions{})
+					return err
+				},
+			}
+
+			err := c.Run(t.Context(), []string{"test", "--log.level=" + test.lvl})
+
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestLogLevel_IncreaseDecrease(t *testing.T) {
+	var buf bytes.Buffer
+	lvl := newTestLogger(t, newLevelLogger, &buf, "--log.level=crit").lvl
+
+	lvl.Decrease()
+	assert.Equal(t, logger.Crit, lvl.Level())
+
+	lvl.Increase()
+	assert.Equal(t, logger.Error, lvl.Level())
+
+	lvl.SetLevel(logger.Trace)
+	lvl.Increase()
+	assert.Equal(t, logger.Trace, lvl.Level())
+
+	lvl.Decrease()
+	assert.Equal(t, logger.Debug, lvl.Level())
+}
+
+func TestLogLevel_ServeHTTP(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		body       string
+		wantStatus int
+		wantBody   string
+		wantLevel  logger.Level
+	}{
+		{
+			name:       "get",
+			method:     http.MethodGet,
+			wantStatus: http.StatusOK,
+			wantBody:   `{"level":"info"}` + "\n",
+			wantLevel:  logger.Info,
+		},
+		{
+			name:       "put",
+			method:     http.MethodPut,
+			body:       `{"level":"debug"}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `{"level":"debug"}` + "\n",
+			wantLevel:  logger.Debug,
+		},
+		{
+			name:       "put invalid level",
+			method:     http.MethodPut,
+			body:       `{"level":"invalid"}`,
+			wantStatus: http.StatusBadRequest,
+			wantLevel:  logger.Info,
+		},
+		{
+			name:       "put invalid body",
+			method:     http.MethodPut,
+			body:       `level`,
+			wantStatus: http.StatusBadRequest,
+			wantLevel:  logger.Info,
+		},
+		{
+			name:       "unsupported method",
+			method:     http.MethodDelete,
+			wantStatus: http.StatusMethodNotAllowed,
+			wantLevel:  logger.Info,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			lvl := newTestLogger(t, newLevelLogger, &buf, "--log.level=info").lvl
+
+			req := httptest.NewRequest(test.method, "/", strings.NewReader(test.body))
+			rec := httptest.NewRecorder()
+
+			lvl.ServeHTTP(rec, req)
+
+			assert.Equal(t, test.wantStatus, rec.Code)
+			if test.wantBody != "" {
+				assert.Equal(t, test.wantBody, rec.Body.String())
+			}
+			assert.Equal(t, test.wantLevel, lvl.Level())
+		})
+	}
+}
+
+// levelLogger is a logger with its level.
+type levelLogger struct {
+	*logger.Logger
+
+	lvl *cmd.LogLevel
+}
+
+func newLevelLogger(c *cli.Command, opts *cmd.LoggerOptions) (levelLogger, error) {
+	log, lvl, err := cmd.NewLoggerWithLevel(c, opts)
+	return levelLogger{Logger: log, lvl: lvl}, err
+}
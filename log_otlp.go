@@ -0,0 +1,180 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/hamba/logger/v2"
+	"github.com/hamba/logger/v2/ctx"
+	"github.com/urfave/cli/v3"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploggrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploghttp"
+	otellog "go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// NewLogProvider returns a log provider configured from the cli, exporting
+// log records to the log exporter. If no log exporter is configured, nil is returned.
+//
+// The provider should be passed to the logger in LoggerOptions, and shutdown
+// to flush the remaining log records.
+func NewLogProvider(ctx context.Context, cmd *cli.Command, attrs ...attribute.KeyValue) (*sdklog.LoggerProvider, error) {
+	exp, err := createLogExporter(ctx, cmd)
+	if err != nil {
+		return nil, err
+	}
+	if exp == nil {
+		//nolint:nilnil // There is no sentinel in this case.
+		return nil, nil
+	}
+
+	return sdklog.NewLoggerProvider(
+		sdklog.WithResource(resource.NewWithAttributes(semconv.SchemaURL, attrs...)),
+		sdklog.WithProcessor(sdklog.NewBatchProcessor(exp)),
+	), nil
+}
+
+func createLogExporter(ctx context.Context, cmd *cli.Command) (sdklog.Exporter, error) {
+	backend := cmd.String(FlagLogExporter)
+	endpoint := cmd.String(FlagLogEndpoint)
+
+	switch backend {
+	case "":
+		return nil, nil //nolint:nilnil
+	case "otlphttp":
+		opts := []otlploghttp.Option{otlploghttp.WithEndpoint(endpoint), otlploghttp.WithHeaders(cmd.StringMap(FlagLogHeaders))}
+		if cmd.Bool(FlagLogEndpointInsecure) {
+			opts = append(opts, otlploghttp.WithInsecure())
+		}
+		return otlploghttp.New(ctx, opts...)
+	case "otlpgrpc":
+		opts := []otlploggrpc.Option{otlploggrpc.WithEndpoint(endpoint), otlploggrpc.WithHeaders(cmd.StringMap(FlagLogHeaders))}
+		if cmd.Bool(FlagLogEndpointInsecure) {
+			opts = append(opts, otlploggrpc.WithInsecure())
+		}
+		return otlploggrpc.New(ctx, opts...)
+	default:
+		return nil, fmt.Errorf("unsupported log exporter %q", backend)
+	}
+}
+
+// TraceFields returns log fields identifying the span in the context.
+// Exported log records with these fields carry the trace and span IDs.
+func TraceFields(c context.Context) []logger.Field {
+	sc := trace.SpanContextFromContext(c)
+	if !sc.IsValid() {
+		return nil
+	}
+
+	return []logger.Field{
+		ctx.Str(logTraceIDKey, sc.TraceID().String()),
+		ctx.Str(logSpanIDKey, sc.SpanID().String()),
+	}
+}
+
+// otelExporter exports log records to an open telemetry logger.
+type otelExporter struct {
+	log otellog.Logger
+}
+
+func newOTelExporter(prov otellog.LoggerProvider) otelExporter {
+	return otelExporter{log: prov.Logger("github.com/hamba/cmd")}
+}
+
+func (e otelExporter) export(rec *logRecord) {
+	now := time.Now()
+	ts := rec.Time
+	if ts.IsZero() {
+		ts = now
+	}
+
+	var r otellog.Record
+	r.SetTimestamp(ts)
+	r.SetObservedTimestamp(now)
+	r.SetSeverity(otelSeverity(rec.Level))
+	r.SetSeverityText(levelName(rec.Level))
+	r.SetBody(otellog.StringValue(rec.Msg))
+
+	var sc trace.SpanContextConfig
+	for _, f := range rec.Fields {
+		switch f.Key {
+		case logTraceIDKey:
+			if s, ok := f.Val.(string); ok {
+				if id, err := trace.TraceIDFromHex(s); err == nil {
+					sc.TraceID = id
+					continue
+				}
+			}
+		case logSpanIDKey:
+			if s, ok := f.Val.(string); ok {
+				if id, err := trace.SpanIDFromHex(s); err == nil {
+					sc.SpanID = id
+					continue
+				}
+			}
+		}
+		r.AddAttributes(otellog.KeyValue{Key: f.Key, Value: otelValue(f.Val)})
+	}
+
+	c := context.Background()
+	if spanCtx := trace.NewSpanContext(sc); spanCtx.IsValid() {
+		c = trace.ContextWithSpanContext(c, spanCtx)
+	}
+	e.log.Emit(c, r)
+}
+
+func otelSeverity(lvl logger.Level) otellog.Severity {
+	switch lvl {
+	case logger.Trace:
+		return otellog.SeverityTrace
+	case logger.Debug:
+		return otellog.SeverityDebug
+	case logger.Info:
+		return otellog.SeverityInfo
+	case logger.Warn:
+		return otellog.SeverityWarn
+	case logger.Error:
+		return otellog.SeverityError
+	case logger.Crit:
+		return otellog.SeverityFatal
+	default:
+		return otellog.SeverityUndefined
+	}
+}
+
+func otelValue(v any) otellog.Value {
+	switch val := v.(type) {
+	case string:
+		return otellog.StringValue(val)
+	case bool:
+		return otellog.BoolValue(val)
+	case int64:
+		return otellog.Int64Value(val)
+	case uint64:
+		if val > math.MaxInt64 {
+			return otellog.StringValue(strconv.FormatUint(val, 10))
+		}
+		return otellog.Int64Value(int64(val))
+	case float64:
+		return otellog.Float64Value(val)
+	case time.Time:
+		return otellog.StringValue(val.Format(time.RFC3339Nano))
+	case time.Duration:
+		return otellog.StringValue(val.String())
+	case []any:
+		vals := make([]otellog.Value, len(val))
+		for i, item := range val {
+			vals[i] = otelValue(item)
+		}
+		return otellog.SliceValue(vals...)
+	default:
+		return otellog.StringValue(fmt.Sprint(val))
+	}
+}
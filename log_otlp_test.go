@@ -0,0 +1,186 @@
+package cmd_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hamba/cmd/v3"
+	"github.com/hamba/logger/v2"
+	"github.com/hamba/logger/v2/ctx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v3"
+	"go.opentelemetry.io/otel/trace"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestNewLogProvider(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantNil bool
+		wantErr require.ErrorAssertionFunc
+	}{
+		{
+			name:    "no exporter",
+			wantNil: true,
+			wantErr: require.NoError,
+		},
+		{
+			name:    "otlphttp",
+			args:    []string{"--log.exporter=otlphttp", "--log.endpoint=localhost:4318", "--log.headers=a=b"},
+			wantErr: require.NoError,
+		},
+		{
+			name:    "otlpgrpc",
+			args:    []string{"--log.exporter=otlpgrpc", "--log.endpoint=localhost:4317", "--log.endpoint-insecure"},
+			wantErr: require.NoError,
+		},
+		{
+			name:    "unknown exporter",
+			args:    []string{"--log.exporter=some-exporter"},
+			wantErr: require.Error,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := &cli.Command{
+				Flags: cmd.LogFlags,
+				Action: func(ctx context.Context, c *cli.Command) error {
+					prov, err := cmd.NewLogProvider(ctx, c)
+					if err != nil {
+						return err
+					}
+					assert.Equal(t, test.wantNil, prov == nil)
+					if prov != nil {
+						_ = prov.Shutdown(ctx)
+					}
+					return nil
+				},
+			}
+
+			err := c.Run(t.Context(), append([]string{"test"}, test.args...))
+
+			test.wantErr(t, err)
+		})
+	}
+}
+
+func TestNewLogger_ExportsRecords(t *testing.T) {
+	recv := &logReceiver{}
+	srv := httptest.NewServer(recv)
+	t.Cleanup(srv.Close)
+
+	var buf bytes.Buffer
+	c := &cli.Command{
+		Flags: cmd.LogFlags,
+		Action: func(c context.Context, cliCmd *cli.Command) error {
+			prov, err := cmd.NewLogProvider(c, cliCmd)
+			if err != nil {
+				return err
+			}
+
+			log, err := cmd.NewLoggerWithOptions(cliCmd, &cmd.LoggerOptions{Writer: &buf, Provider: prov})
+			if err != nil {
+				return err
+			}
+
+			spanCtx := trace.ContextWithSpanContext(c, trace.NewSpanContext(trace.SpanContextConfig{
+				TraceID: trace.TraceID{1, 2, 3},
+				SpanID:  trace.SpanID{4, 5, 6},
+			}))
+			log.Debug("dropped")
+			log.With(cmd.TraceFields(spanCtx)...).Info("in span", ctx.Int("int", 1), ctx.Strs("strs", []string{"a", "b"}))
+			log.Error("not in span", ctx.Bool("bool", true))
+
+			return prov.Shutdown(c)
+		},
+	}
+
+	err := c.Run(t.Context(), []string{
+		"test",
+		"--log.format=logfmt",
+		"--log.ctx=a=b",
+		"--log.exporter=otlphttp",
+		"--log.endpoint=" + strings.TrimPrefix(srv.URL, "http://"),
+		"--log.endpoint-insecure",
+	})
+
+	require.NoError(t, err)
+	want := "lvl=info msg=\"in span\" a=b trace_id=01020300000000000000000000000000 span_id=0405060000000000 int=1 strs=a,b\n" +
+		"lvl=eror msg=\"not in span\" a=b bool=true\n"
+	assert.Equal(t, want, buf.String())
+
+	recs := recv.Records()
+	require.Len(t, recs, 2)
+	assert.Equal(t, "in span", recs[0].GetBody().GetStringValue())
+	assert.Equal(t, logspb.SeverityNumber_SEVERITY_NUMBER_INFO, recs[0].GetSeverityNumber())
+	assert.Equal(t, []byte{1, 2, 3, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}, recs[0].GetTraceId())
+	assert.Equal(t, []byte{4, 5, 6, 0, 0, 0, 0, 0}, recs[0].GetSpanId())
+	attrs := recs[0].GetAttributes()
+	require.Len(t, attrs, 3)
+	assert.Equal(t, "a", attrs[0].GetKey())
+	assert.Equal(t, "b", attrs[0].GetValue().GetStringValue())
+	assert.Equal(t, "int", attrs[1].GetKey())
+	assert.Equal(t, int64(1), attrs[1].GetValue().GetIntValue())
+	assert.Equal(t, "strs", attrs[2].GetKey())
+	assert.Len(t, attrs[2].GetValue().GetArrayValue().GetValues(), 2)
+	assert.Equal(t, "not in span", recs[1].GetBody().GetStringValue())
+	assert.Equal(t, logspb.SeverityNumber_SEVERITY_NUMBER_ERROR, recs[1].GetSeverityNumber())
+	assert.Empty(t, recs[1].GetTraceId())
+}
+
+func TestTraceFields(t *testing.T) {
+	var buf bytes.Buffer
+	log := logger.New(&buf, logger.LogfmtFormat(), logger.Info)
+
+	log.Info("no span", cmd.TraceFields(t.Context())...)
+
+	assert.Equal(t, "lvl=info msg=\"no span\"\n", buf.String())
+}
+
+type logReceiver struct {
+	mu   sync.Mutex
+	recs []*logspb.LogRecord
+}
+
+func (r *logReceiver) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	b, err := io.ReadAll(req.Body)
+	if err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var exp collogspb.ExportLogsServiceRequest
+	if err = proto.Unmarshal(b, &exp); err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	r.mu.Lock()
+	for _, rl := range exp.GetResourceLogs() {
+		for _, sl := range rl.GetScopeLogs() {
+			r.recs = append(r.recs, sl.GetLogRecords()...)
+		}
+	}
+	r.mu.Unlock()
+
+	b, _ = proto.Marshal(&collogspb.ExportLogsServiceResponse{})
+	rw.Header().Set("Content-Type", "application/x-protobuf")
+	_, _ = rw.Write(b)
+}
+
+func (r *logReceiver) Records() []*logspb.LogRecord {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.recs
+}
@@ -0,0 +1,400 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/hamba/logger/v2"
+)
+
+// Log field keys with a special meaning to the record writer.
+const (
+	logComponentKey = "component"
+	logTraceIDKey   = "trace_id"
+	logSpanIDKey    = "span_id"
+)
+
+// frameMarker starts a record frame within a formatted line. The formatters
+// escape control characters in values, and the record formatter replaces them
+// in keys, so it cannot appear in a formatted line.
+const frameMarker = '\x00'
+
+// Record frame kinds.
+const (
+	frameMessage byte = iota + 1
+	frameKey
+	frameString
+	frameBool
+	frameInt
+	frameUint
+	frameFloat
+	frameTime
+	frameDuration
+	frameArrayStart
+	frameArrayEnd
+	// frameSink starts the output of a sink. It is kept in
+	// the line by the record writer for the sink writer.
+	frameSink
+	// frameSkip replaces a line below the dynamic level,
+	// which the record writer then discards.
+	frameSkip
+)
+
+// componentKeyFrame is the frame written for the component key.
+var componentKeyFrame = append(
+	binary.AppendUvarint([]byte{frameMarker, frameKey}, uint64(len(logComponentKey))),
+	logComponentKey...,
+)
+
+// recordFormatter records each formatted log line in frames, which
+// are split from the line by the record writer.
+//
+// When not full, only the message and component are recorded.
+type recordFormatter[B buffer] struct {
+	formatter[B]
+
+	full bool
+}
+
+func newRecordFormatter[B buffer](f formatter[B], full bool) logger.Formatter {
+	return asFormatter[B](recordFormatter[B]{formatter: f, full: full})
+}
+
+func (f recordFormatter[B]) writeFrame(buf B, kind byte, payload []byte) {
+	var hdr [2 + binary.MaxVarintLen64]byte
+	hdr[0], hdr[1] = frameMarker, kind
+	n := binary.PutUvarint(hdr[2:], uint64(len(payload)))
+	buf.Write(hdr[:2+n])
+	buf.Write(payload)
+}
+
+func (f recordFormatter[B]) writeStringFrame(buf B, kind byte, s string) {
+	var hdr [2 + binary.MaxVarintLen64]byte
+	hdr[0], hdr[1] = frameMarker, kind
+	n := binary.PutUvarint(hdr[2:], uint64(len(s)))
+	buf.Write(hdr[:2+n])
+	buf.WriteString(s)
+}
+
+func (f recordFormatter[B]) WriteMessage(buf B, ts time.Time, lvl logger.Level, msg string) {
+	var hdr [binary.MaxVarintLen64 + 1]byte
+	var nanos int64
+	if !ts.IsZero() {
+		nanos = ts.UnixNano()
+	}
+	n := binary.PutVarint(hdr[:], nanos)
+	hdr[n] = byte(lvl)
+
+	var frameHdr [2 + binary.MaxVarintLen64]byte
+	frameHdr[0], frameHdr[1] = frameMarker, frameMessage
+	m := binary.PutUvarint(frameHdr[2:], uint64(n+1+len(msg)))
+	buf.Write(frameHdr[:2+m])
+	buf.Write(hdr[:n+1])
+	buf.WriteString(msg)
+
+	f.formatter.WriteMessage(buf, ts, lvl, msg)
+}
+
+func (f recordFormatter[B]) AppendCaller(buf B, frame runtime.Frame) {
+	if f.full {
+		f.writeStringFrame(buf, frameKey, logCallerKey)
+		f.writeStringFrame(buf, frameString, callerString(frame))
+	}
+	appendCaller(f.formatter, buf, frame)
+}
+
+func (f recordFormatter[B]) AppendArrayStart(buf B) {
+	f.formatter.AppendArrayStart(buf)
+	if f.full {
+		f.writeFrame(buf, frameArrayStart, nil)
+	}
+}
+
+func (f recordFormatter[B]) AppendArrayEnd(buf B) {
+	f.formatter.AppendArrayEnd(buf)
+	if f.full {
+		f.writeFrame(buf, frameArrayEnd, nil)
+	}
+}
+
+func (f recordFormatter[B]) AppendKey(buf B, key string) {
+	key = replaceControl(key)
+	f.formatter.AppendKey(buf, key)
+	if f.full || key == logComponentKey {
+		f.writeStringFrame(buf, frameKey, key)
+	}
+}
+
+func (f recordFormatter[B]) AppendString(buf B, s string) {
+	if f.full || bytes.HasSuffix(buf.Bytes(), componentKeyFrame) {
+		f.writeStringFrame(buf, frameString, s)
+	}
+	f.formatter.AppendString(buf, s)
+}
+
+func (f recordFormatter[B]) AppendBool(buf B, b bool) {
+	if f.full {
+		var v byte
+		if b {
+			v = 1
+		}
+		f.writeFrame(buf, frameBool, []byte{v})
+	}
+	f.formatter.AppendBool(buf, b)
+}
+
+func (f recordFormatter[B]) AppendInt(buf B, i int64) {
+	if f.full {
+		var p [binary.MaxVarintLen64]byte
+		f.writeFrame(buf, frameInt, p[:binary.PutVarint(p[:], i)])
+	}
+	f.formatter.AppendInt(buf, i)
+}
+
+func (f recordFormatter[B]) AppendUint(buf B, i uint64) {
+	if f.full {
+		var p [binary.MaxVarintLen64]byte
+		f.writeFrame(buf, frameUint, p[:binary.PutUvarint(p[:], i)])
+	}
+	f.formatter.AppendUint(buf, i)
+}
+
+func (f recordFormatter[B]) AppendFloat(buf B, v float64) {
+	if f.full {
+		var p [8]byte
+		binary.LittleEndian.PutUint64(p[:], math.Float64bits(v))
+		f.writeFrame(buf, frameFloat, p[:])
+	}
+	f.formatter.AppendFloat(buf, v)
+}
+
+func (f recordFormatter[B]) AppendTime(buf B, t time.Time) {
+	if f.full {
+		var p [binary.MaxVarintLen64]byte
+		f.writeFrame(buf, frameTime, p[:binary.PutVarint(p[:], t.UnixNano())])
+	}
+	f.formatter.AppendTime(buf, t)
+}
+
+func (f recordFormatter[B]) AppendDuration(buf B, d time.Duration) {
+	if f.full {
+		var p [binary.MaxVarintLen64]byte
+		f.writeFrame(buf, frameDuration, p[:binary.PutVarint(p[:], int64(d))])
+	}
+	f.formatter.AppendDuration(buf, d)
+}
+
+func (f recordFormatter[B]) AppendInterface(buf B, v any) {
+	if f.full && v != nil {
+		f.writeStringFrame(buf, frameString, fmt.Sprintf("%+v", v))
+	}
+	f.formatter.AppendInterface(buf, v)
+}
+
+// replaceControl replaces the control characters in the key, which the
+// formatters write as is, so that it cannot be taken for a frame.
+func replaceControl(key string) string {
+	if !strings.ContainsFunc(key, unicode.IsControl) {
+		return key
+	}
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return '_'
+		}
+		return r
+	}, key)
+}
+
+// logField is a recorded log field.
+//
+// The value is one of string, bool, int64, uint64, float64,
+// time.Time, time.Duration or []any of these.
+type logField struct {
+	Key string
+	Val any
+}
+
+// logRecord is a log line recorded by the record formatter.
+//
+// RawMsg is the message in the line, which is set even when only
+// the message and component are recorded.
+type logRecord struct {
+	Time      time.Time
+	Level     logger.Level
+	Msg       string
+	RawMsg    []byte
+	Component []byte
+	Fields    []logField
+}
+
+func (r *logRecord) reset() {
+	*r = logRecord{Fields: r.Fields[:0]}
+}
+
+// recordExporter exports log records.
+type recordExporter interface {
+	export(rec *logRecord)
+}
+
+// levelWriter is a writer that is given the level of each line.
+type levelWriter interface {
+	writeLevel(lvl logger.Level, p []byte) (int, error)
+}
+
+// asLevelWriter returns the level writer w writes to, if any.
+func asLevelWriter(w any) (levelWriter, bool) {
+	if aw, ok := w.(*AsyncWriter); ok && aw.lw == nil {
+		return nil, false
+	}
+	lw, ok := w.(levelWriter)
+	return lw, ok
+}
+
+var recordPool = sync.Pool{
+	New: func() any {
+		return &recordLine{line: make([]byte, 0, 512)}
+	},
+}
+
+type recordLine struct {
+	line []byte
+	rec  logRecord
+}
+
+// recordWriter splits the records from lines written by the record formatter,
+// filtering the lines by level, sampling them and exporting the records. Lines
+// are written with their level when the writer is a level writer.
+type recordWriter struct {
+	w   io.Writer
+	lw  levelWriter
+	lvl *LogLevel
+	smp *logSampler
+	exp recordExporter
+}
+
+func (w recordWriter) Write(p []byte) (int, error) {
+	if isSkipped(p) {
+		return len(p), nil
+	}
+
+	rl := recordPool.Get().(*recordLine)
+	defer recordPool.Put(rl)
+
+	rl.rec.reset()
+	rl.line = splitRecord(rl.line[:0], p, &rl.rec, w.exp != nil)
+
+	if w.lvl != nil && rl.rec.Level > w.lvl.componentLevel(rl.rec.Component) {
+		return len(p), nil
+	}
+	if w.smp != nil {
+		if !w.smp.sample(rl.rec.Level, rl.rec.RawMsg) {
+			return len(p), nil
+		}
+	}
+	if w.exp != nil {
+		w.exp.export(&rl.rec)
+	}
+	if w.lw != nil {
+		if _, err := w.lw.writeLevel(rl.rec.Level, rl.line); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+	if _, err := w.w.Write(rl.line); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// splitRecord appends the formatted line in p to line, decoding its frames into rec.
+// Only the message and component are decoded unless full is set.
+//
+//nolint:cyclop // Splitting this would not make it simpler.
+func splitRecord(line, p []byte, rec *logRecord, full bool) []byte {
+	var (
+		key     string
+		isComp  bool
+		array   []any
+		inArray bool
+	)
+	addVal := func(v any) {
+		switch {
+		case inArray:
+			array = append(array, v)
+		case full:
+			rec.Fields = append(rec.Fields, logField{Key: key, Val: v})
+		}
+	}
+
+	for {
+		i := bytes.IndexByte(p, frameMarker)
+		if i < 0 || i+2 > len(p) {
+			return append(line, p...)
+		}
+		line = append(line, p[:i]...)
+
+		kind := p[i+1]
+		l, n := binary.Uvarint(p[i+2:])
+		if n <= 0 || uint64(len(p[i+2+n:])) < l {
+			// This cannot happen for frames written by the record formatter.
+			return line
+		}
+		frame := p[i : i+2+n+int(l)]
+		payload := frame[2+n:]
+		p = p[i+2+n+int(l):]
+
+		switch kind {
+		case frameMessage:
+			nanos, m := binary.Varint(payload)
+			if nanos != 0 {
+				rec.Time = time.Unix(0, nanos)
+			}
+			rec.Level = logger.Level(payload[m])
+			rec.RawMsg = payload[m+1:]
+			if full {
+				rec.Msg = string(payload[m+1:])
+			}
+		case frameKey:
+			key = string(payload)
+			isComp = key == logComponentKey
+		case frameString:
+			if isComp && !inArray {
+				rec.Component = payload
+			}
+			if full {
+				addVal(string(payload))
+			}
+		case frameBool:
+			addVal(payload[0] == 1)
+		case frameInt:
+			v, _ := binary.Varint(payload)
+			addVal(v)
+		case frameUint:
+			v, _ := binary.Uvarint(payload)
+			addVal(v)
+		case frameFloat:
+			addVal(math.Float64frombits(binary.LittleEndian.Uint64(payload)))
+		case frameTime:
+			v, _ := binary.Varint(payload)
+			addVal(time.Unix(0, v))
+		case frameDuration:
+			v, _ := binary.Varint(payload)
+			addVal(time.Duration(v))
+		case frameArrayStart:
+			array, inArray = nil, true
+		case frameArrayEnd:
+			inArray = false
+			addVal(array)
+		case frameSink:
+			line = append(line, frame...)
+		}
+	}
+}
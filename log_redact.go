@@ -0,0 +1,221 @@
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/hamba/logger/v2"
+)
+
+// redactedValue replaces redacted values.
+const redactedValue = "[REDACTED]"
+
+// Markers written by the redact formatter, which are removed by the
+// next append. The formatters escape control characters in values,
+// so the markers cannot appear in a formatted line.
+const (
+	redactMarker      = '\x01'
+	redactArrayMarker = '\x02'
+)
+
+// redactor redacts log field values by key and value pattern.
+type redactor struct {
+	keys     map[string]struct{}
+	patterns []*regexp.Regexp
+}
+
+// parseRedactor parses redaction rules. Rules in the form `/pattern/`
+// are value patterns, all other rules are field keys.
+func parseRedactor(rules []string) (*redactor, error) {
+	r := &redactor{keys: map[string]struct{}{}}
+	for _, rule := range rules {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+
+		if len(rule) > 1 && strings.HasPrefix(rule, "/") && strings.HasSuffix(rule, "/") {
+			re, err := regexp.Compile(rule[1 : len(rule)-1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid redact pattern %q: %w", rule, err)
+			}
+			r.patterns = append(r.patterns, re)
+			continue
+		}
+		r.keys[strings.ToLower(rule)] = struct{}{}
+	}
+
+	if len(r.keys) == 0 && len(r.patterns) == 0 {
+		return nil, nil
+	}
+	return r, nil
+}
+
+func (r *redactor) isRedactedKey(key string) bool {
+	_, ok := r.keys[strings.ToLower(key)]
+	return ok
+}
+
+// redactString replaces pattern matches in s. If a pattern has
+// capture groups, only the groups are replaced.
+func (r *redactor) redactString(s string) string {
+	for _, re := range r.patterns {
+		idxs := re.FindAllStringSubmatchIndex(s, -1)
+		if len(idxs) == 0 {
+			continue
+		}
+
+		var (
+			sb   strings.Builder
+			last int
+		)
+		for _, idx := range idxs {
+			groups := idx[2:]
+			if len(groups) == 0 {
+				groups = idx[:2]
+			}
+			for i := 0; i < len(groups); i += 2 {
+				start, end := groups[i], groups[i+1]
+				if start < last || start < 0 {
+					continue
+				}
+				sb.WriteString(s[last:start])
+				sb.WriteString(redactedValue)
+				last = end
+			}
+		}
+		sb.WriteString(s[last:])
+		s = sb.String()
+	}
+	return s
+}
+
+// redactFormatter redacts values before they are formatted.
+type redactFormatter[B buffer] struct {
+	formatter[B]
+
+	r *redactor
+}
+
+func newRedactFormatter[B buffer](f formatter[B], r *redactor) logger.Formatter {
+	return asFormatter[B](redactFormatter[B]{formatter: f, r: r})
+}
+
+// redacted reports if the value being appended belongs to a
+// redacted key, in which case the value should be skipped.
+// The redact marker is replaced by the redacted value.
+func (f redactFormatter[B]) redacted(buf B) bool {
+	b := buf.Bytes()
+	if len(b) == 0 {
+		return false
+	}
+
+	switch b[len(b)-1] {
+	case redactMarker:
+		buf.Reset()
+		buf.Write(b[:len(b)-1])
+		f.formatter.AppendString(buf, redactedValue)
+		return true
+	case redactArrayMarker:
+		return true
+	default:
+		return false
+	}
+}
+
+func (f redactFormatter[B]) WriteMessage(buf B, ts time.Time, lvl logger.Level, msg string) {
+	f.formatter.WriteMessage(buf, ts, lvl, f.r.redactString(msg))
+}
+
+func (f redactFormatter[B]) AppendArrayStart(buf B) {
+	if f.redacted(buf) {
+		buf.WriteString(string(redactArrayMarker))
+		return
+	}
+	f.formatter.AppendArrayStart(buf)
+}
+
+func (f redactFormatter[B]) AppendArraySep(buf B) {
+	if f.redacted(buf) {
+		return
+	}
+	f.formatter.AppendArraySep(buf)
+}
+
+func (f redactFormatter[B]) AppendArrayEnd(buf B) {
+	if b := buf.Bytes(); len(b) > 0 && b[len(b)-1] == redactArrayMarker {
+		buf.Reset()
+		buf.Write(b[:len(b)-1])
+		return
+	}
+	f.formatter.AppendArrayEnd(buf)
+}
+
+func (f redactFormatter[B]) AppendKey(buf B, key string) {
+	f.formatter.AppendKey(buf, key)
+	if f.r.isRedactedKey(key) {
+		buf.WriteString(string(redactMarker))
+	}
+}
+
+func (f redactFormatter[B]) AppendString(buf B, s string) {
+	if f.redacted(buf) {
+		return
+	}
+	f.formatter.AppendString(buf, f.r.redactString(s))
+}
+
+func (f redactFormatter[B]) AppendBool(buf B, b bool) {
+	if f.redacted(buf) {
+		return
+	}
+	f.formatter.AppendBool(buf, b)
+}
+
+func (f redactFormatter[B]) AppendInt(buf B, i int64) {
+	if f.redacted(buf) {
+		return
+	}
+	f.formatter.AppendInt(buf, i)
+}
+
+func (f redactFormatter[B]) AppendUint(buf B, i uint64) {
+	if f.redacted(buf) {
+		return
+	}
+	f.formatter.AppendUint(buf, i)
+}
+
+func (f redactFormatter[B]) AppendFloat(buf B, v float64) {
+	if f.redacted(buf) {
+		return
+	}
+	f.formatter.AppendFloat(buf, v)
+}
+
+func (f redactFormatter[B]) AppendTime(buf B, t time.Time) {
+	if f.redacted(buf) {
+		return
+	}
+	f.formatter.AppendTime(buf, t)
+}
+
+func (f redactFormatter[B]) AppendDuration(buf B, d time.Duration) {
+	if f.redacted(buf) {
+		return
+	}
+	f.formatter.AppendDuration(buf, d)
+}
+
+func (f redactFormatter[B]) AppendInterface(buf B, v any) {
+	if f.redacted(buf) {
+		return
+	}
+	if v == nil || len(f.r.patterns) == 0 {
+		f.formatter.AppendInterface(buf, v)
+		return
+	}
+	f.formatter.AppendInterface(buf, f.r.redactString(fmt.Sprintf("%+v", v)))
+}
@@ -0,0 +1,198 @@
+package cmd
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hamba/logger/v2"
+	"github.com/hamba/logger/v2/ctx"
+)
+
+// logSampler samples log lines with the same level and message, keeping
+// the first lines in each interval and every nth line thereafter.
+//
+// The number of dropped lines is logged every interval by a ticker, which
+// runs while lines are sampled and stops once none were sampled for an interval
+// or the sampler is closed.
+type logSampler struct {
+	first      uint64
+	thereafter uint64
+	interval   time.Duration
+
+	mu      sync.RWMutex
+	counts  map[logger.Level]map[string]*sampleCount
+	running bool
+	closed  bool
+	stop    chan struct{}
+	wg      sync.WaitGroup
+
+	// log logs the summaries. It is not sampled or filtered by level.
+	log *logger.Logger
+}
+
+type sampleCount struct {
+	n       atomic.Uint64
+	dropped atomic.Uint64
+}
+
+// parseLogSampler parses the sampler options, in the format
+// `first=100,thereafter=100,interval=1s`. If no options are given, nil is returned.
+func parseLogSampler(opts map[string]string) (*logSampler, error) {
+	if len(opts) == 0 {
+		return nil, nil //nolint:nilnil
+	}
+
+	s := &logSampler{
+		first:      100,
+		thereafter: 100,
+		interval:   time.Second,
+		counts:     map[logger.Level]map[string]*sampleCount{},
+		stop:       make(chan struct{}),
+	}
+	for k, v := range opts {
+		var err error
+		switch k {
+		case "first":
+			s.first, err = strconv.ParseUint(v, 10, 64)
+		case "thereafter":
+			s.thereafter, err = strconv.ParseUint(v, 10, 64)
+		case "interval":
+			s.interval, err = time.ParseDuration(v)
+			if err == nil && s.interval <= 0 {
+				err = errors.New("must be positive")
+			}
+		default:
+			return nil, fmt.Errorf("unknown log sample option %q", k)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("log sample option %q: %w", k, err)
+		}
+	}
+	return s, nil
+}
+
+// sample reports if the line with the given level and message should be kept.
+func (s *logSampler) sample(lvl logger.Level, msg []byte) bool {
+	s.mu.RLock()
+	c := s.counts[lvl][string(msg)]
+	s.mu.RUnlock()
+	if c == nil {
+		c = s.count(lvl, msg)
+	}
+
+	n := c.n.Add(1)
+	if n <= s.first || (s.thereafter > 0 && (n-s.first)%s.thereafter == 0) {
+		return true
+	}
+	c.dropped.Add(1)
+	return false
+}
+
+// count returns the count of the level and message, adding it if needed
+// and starting the ticker when it is not running.
+func (s *logSampler) count(lvl logger.Level, msg []byte) *sampleCount {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	msgs, ok := s.counts[lvl]
+	if !ok {
+		msgs = map[string]*sampleCount{}
+		s.counts[lvl] = msgs
+	}
+	c, ok := msgs[string(msg)]
+	if !ok {
+		c = &sampleCount{}
+		msgs[string(msg)] = c
+	}
+
+	if !s.running && !s.closed {
+		s.running = true
+		s.wg.Add(1)
+		go s.run()
+	}
+	return c
+}
+
+// run summarizes the sampled lines every interval, until none were sampled for an interval.
+func (s *logSampler) run() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			if !s.summarize() {
+				return
+			}
+		}
+	}
+}
+
+// close stops the ticker and logs the lines dropped in the current interval.
+// It must be called before the writer is closed.
+func (s *logSampler) close() {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.closed = true
+	close(s.stop)
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	s.summarize()
+}
+
+type sampleSummary struct {
+	lvl     logger.Level
+	msg     string
+	dropped uint64
+}
+
+// summarize logs the number of dropped lines of each message and starts
+// the next interval, removing the messages not logged in this one. It
+// reports if lines are still being sampled.
+func (s *logSampler) summarize() bool {
+	var sums []sampleSummary
+
+	s.mu.Lock()
+	for lvl, msgs := range s.counts {
+		for msg, c := range msgs {
+			if n := c.dropped.Swap(0); n > 0 {
+				sums = append(sums, sampleSummary{lvl: lvl, msg: msg, dropped: n})
+			}
+			if c.n.Swap(0) == 0 {
+				delete(msgs, msg)
+			}
+		}
+		if len(msgs) == 0 {
+			delete(s.counts, lvl)
+		}
+	}
+	s.running = len(s.counts) > 0
+	running := s.running
+	s.mu.Unlock()
+
+	slices.SortFunc(sums, func(a, b sampleSummary) int {
+		return cmp.Or(cmp.Compare(a.lvl, b.lvl), cmp.Compare(a.msg, b.msg))
+	})
+	for _, sum := range sums {
+		s.log.Warn("Log lines dropped by sampling",
+			ctx.Str("sampled_msg", sum.msg),
+			ctx.Str("sampled_lvl", levelName(sum.lvl)),
+			ctx.Uint64("dropped", sum.dropped),
+		)
+	}
+	return running
+}
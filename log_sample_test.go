@@ -0,0 +1,151 @@
+package cmd_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hamba/cmd/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v3"
+)
+
+func TestNewLogger_Sample(t *testing.T) {
+	var buf bytes.Buffer
+	log := newTestLogger(t, cmd.NewLoggerWithOptions, &buf, "--log.sample=first=2,thereafter=3,interval=1h")
+
+	for range 10 {
+		log.Info("repeated")
+	}
+	log.Error("repeated")
+
+	want := "lvl=info msg=repeated\n" +
+		"lvl=info msg=repeated\n" +
+		"lvl=info msg=repeated\n" +
+		"lvl=info msg=repeated\n" +
+		"lvl=eror msg=repeated\n"
+	assert.Equal(t, want, buf.String())
+}
+
+func TestNewLogger_SampleSummary(t *testing.T) {
+	var buf syncBuffer
+	log := newTestLogger(t, cmd.NewLoggerWithOptions, &buf, "--log.level=error", "--log.ctx=env=prod", "--log.sample=first=1,thereafter=0,interval=20ms")
+
+	for range 3 {
+		log.Error("a")
+	}
+	for range 2 {
+		log.Error("b")
+	}
+
+	// The summary is logged once the interval ends, without further logging.
+	want := "lvl=eror msg=a env=prod\n" +
+		"lvl=eror msg=b env=prod\n" +
+		"lvl=warn msg=\"Log lines dropped by sampling\" env=prod sampled_msg=a sampled_lvl=error dropped=2\n" +
+		"lvl=warn msg=\"Log lines dropped by sampling\" env=prod sampled_msg=b sampled_lvl=error dropped=1\n"
+	assert.EventuallyWithT(t, func(c *assert.CollectT) {
+		assert.Equal(c, want, buf.String())
+	}, time.Second, 5*time.Millisecond)
+
+	log.Error("a")
+
+	assert.Equal(t, want+"lvl=eror msg=a env=prod\n", buf.String())
+}
+
+func TestNewLogger_SampleSummaryOnClose(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "app.log")
+
+	c := &cli.Command{
+		Flags: cmd.LogFlags,
+		Action: func(_ context.Context, c *cli.Command) error {
+			w, err := cmd.NewLogWriter(c)
+			if err != nil {
+				return err
+			}
+			log, err := cmd.NewLoggerWithOptions(c, &cmd.LoggerOptions{Writer: w})
+			if err != nil {
+				return err
+			}
+
+			for range 3 {
+				log.Error("a")
+			}
+			return w.Close()
+		},
+	}
+
+	err := c.Run(t.Context(), []string{"test", "--log.file=" + file, "--log.async", "--log.sample=first=1,thereafter=0,interval=1h"})
+	require.NoError(t, err)
+
+	// The summary of the current interval is written before the writer is closed.
+	got, err := os.ReadFile(file)
+	require.NoError(t, err)
+	want := "lvl=eror msg=a\n" +
+		"lvl=warn msg=\"Log lines dropped by sampling\" sampled_msg=a sampled_lvl=error dropped=2\n"
+	assert.Equal(t, want, string(got))
+}
+
+func TestNewLogger_SampleErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{
+			name: "unknown option",
+			args: []string{"--log.sample=every=2"},
+		},
+		{
+			name: "invalid first",
+			args: []string{"--log.sample=first=-1"},
+		},
+		{
+			name: "invalid thereafter",
+			args: []string{"--log.sample=thereafter=a"},
+		},
+		{
+			name: "invalid interval",
+			args: []string{"--log.sample=interval=0s"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := &cli.Command{
+				Flags: cmd.LogFlags,
+				Action: func(_ context.Context, c *cli.Command) error {
+					_, err := cmd.NewLogger(c)
+					return err
+				},
+			}
+
+			err := c.Run(t.Context(), append([]string{"test"}, test.args...))
+
+			assert.Error(t, err)
+		})
+	}
+}
+
+// syncBuffer is a buffer that can be written to while it is read.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.String()
+}
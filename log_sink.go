@@ -0,0 +1,384 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hamba/logger/v2"
+	"github.com/urfave/cli/v3"
+)
+
+// logSink is a log destination with its own format and level.
+type logSink struct {
+	w    io.Writer
+	lw   levelWriter
+	fmtr logger.Formatter
+	lvl  logger.Level
+}
+
+// sinkWriter writes the output of each sink, formatted by the tee
+// formatter, to the sink.
+type sinkWriter struct {
+	sinks []logSink
+
+	hooks closeHooks
+}
+
+// newSinkWriter returns a sink writer for the given sink specs,
+// in the form `destination?format=json&level=debug`.
+func newSinkWriter(cmd *cli.Command, specs []string) (*sinkWriter, error) {
+	sw := &sinkWriter{sinks: make([]logSink, 0, len(specs))}
+	for i, spec := range specs {
+		sink, err := newLogSink(cmd, spec)
+		if err != nil {
+			_ = sw.Close()
+			// The spec is not part of the error, as it may contain credentials.
+			return nil, fmt.Errorf("log sink %d: %w", i+1, err)
+		}
+		sw.sinks = append(sw.sinks, sink)
+	}
+	return sw, nil
+}
+
+func newLogSink(cmd *cli.Command, spec string) (logSink, error) {
+	fmtr, err := newLogFormatter(cmd)
+	if err != nil {
+		return logSink{}, err
+	}
+	target, sink, err := parseLogSink(cmd, spec, fmtr)
+	if err != nil {
+		return logSink{}, err
+	}
+
+	var w io.WriteCloser
+	if path, ok := strings.CutPrefix(target, "file://"); ok {
+		w, err = newLogFile(cmd, path)
+	} else {
+		w, err = newLogTarget(cmd, target)
+	}
+	if err != nil {
+		return logSink{}, err
+	}
+	sink.w = w
+	sink.lw, _ = asLevelWriter(w)
+	return sink, nil
+}
+
+// sinkMaxLevel returns the most verbose level lines can reach the sinks at,
+// being the most verbose of the log level and the component levels.
+func sinkMaxLevel(cmd *cli.Command) logger.Level {
+	lvl, components, err := parseLogLevel(cmd.String(FlagLogLevel))
+	if err != nil {
+		// The log level is reported as invalid on its own.
+		return logger.Trace
+	}
+	for _, l := range components {
+		lvl = max(lvl, l)
+	}
+	return lvl
+}
+
+// parseLogSink parses the sink spec, returning the sink target
+// and the sink without its writer. As lines are filtered by the log
+// level before reaching the sinks, a sink level more verbose than
+// the log level is rejected.
+func parseLogSink(cmd *cli.Command, spec string, fmtr logger.Formatter) (string, logSink, error) {
+	maxLvl := sinkMaxLevel(cmd)
+	target, query, _ := strings.Cut(spec, "?")
+	params, err := url.ParseQuery(query)
+	if err != nil {
+		return "", logSink{}, err
+	}
+
+	sink := logSink{fmtr: fmtr, lvl: logger.Trace}
+	for k, v := range params {
+		switch k {
+		case "format":
+			if sink.fmtr, err = logFormatter(cmd, v[0]); err != nil {
+				return "", logSink{}, err
+			}
+		case "level":
+			if sink.lvl, err = logger.LevelFromString(v[0]); err != nil {
+				return "", logSink{}, err
+			}
+			if sink.lvl > maxLvl {
+				return "", logSink{}, fmt.Errorf("level %q is more verbose than --%s", v[0], FlagLogLevel)
+			}
+		default:
+			return "", logSink{}, fmt.Errorf("unknown parameter %q", k)
+		}
+	}
+
+	if path, ok := strings.CutPrefix(target, "file://"); ok {
+		if path == "" {
+			return "", logSink{}, errors.New("file path is required")
+		}
+		return target, sink, nil
+	}
+	if err = validateLogTarget(target); err != nil {
+		return "", logSink{}, err
+	}
+	return target, sink, nil
+}
+
+// asSinkWriter returns the sink writer w writes to, if any.
+func asSinkWriter(w io.Writer) (*sinkWriter, bool) {
+	if aw, ok := w.(*AsyncWriter); ok {
+		w = aw.w
+	}
+	sw, ok := w.(*sinkWriter)
+	return sw, ok
+}
+
+func (w *sinkWriter) formatters() []logger.Formatter {
+	fmtrs := make([]logger.Formatter, len(w.sinks))
+	for i, sink := range w.sinks {
+		fmtrs[i] = sink.fmtr
+	}
+	return fmtrs
+}
+
+var sinkLinesPool = sync.Pool{
+	New: func() any {
+		return &[][]byte{}
+	},
+}
+
+// Write writes the line at info level.
+func (w *sinkWriter) Write(p []byte) (int, error) {
+	return w.writeLevel(logger.Info, p)
+}
+
+func (w *sinkWriter) writeLevel(lvl logger.Level, p []byte) (int, error) {
+	lp := sinkLinesPool.Get().(*[][]byte)
+	defer sinkLinesPool.Put(lp)
+
+	lines := *lp
+	for len(lines) < len(w.sinks) {
+		lines = append(lines, make([]byte, 0, 512))
+	}
+	for i := range lines {
+		lines[i] = lines[i][:0]
+	}
+	*lp = lines
+
+	splitSinks(lines, p)
+
+	var errs []error
+	for i, sink := range w.sinks {
+		if lvl > sink.lvl {
+			continue
+		}
+
+		var err error
+		if sink.lw != nil {
+			_, err = sink.lw.writeLevel(lvl, lines[i])
+		} else {
+			_, err = sink.w.Write(lines[i])
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return 0, errors.Join(errs...)
+	}
+	return len(p), nil
+}
+
+// splitSinks appends the output of each sink in p to its line.
+func splitSinks(lines [][]byte, p []byte) {
+	idx := -1
+	for {
+		i := bytes.IndexByte(p, frameMarker)
+		if i < 0 || i+2 > len(p) || p[i+1] != frameSink {
+			if idx >= 0 {
+				lines[idx] = append(lines[idx], p...)
+			}
+			return
+		}
+		if idx >= 0 {
+			lines[idx] = append(lines[idx], p[:i]...)
+		}
+
+		l, n := binary.Uvarint(p[i+2:])
+		if n <= 0 || uint64(len(p[i+2+n:])) < l {
+			// This cannot happen for frames written by the tee formatter.
+			return
+		}
+		v, _ := binary.Uvarint(p[i+2+n : i+2+n+int(l)])
+		if v >= uint64(len(lines)) {
+			return
+		}
+		idx = int(v)
+		p = p[i+2+n+int(l):]
+	}
+}
+
+func (w *sinkWriter) closeHooks() *closeHooks { return &w.hooks }
+
+// Close closes the sink writers.
+func (w *sinkWriter) Close() error {
+	w.hooks.run()
+
+	var errs []error
+	for _, sink := range w.sinks {
+		if c, ok := sink.w.(io.Closer); ok {
+			if err := c.Close(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// teeFormatter formats each log line with the formatter of every sink,
+// starting the output of each sink with a sink frame.
+type teeFormatter[B buffer] struct {
+	fmtrs []formatter[B]
+}
+
+// newTeeFormatter returns a tee formatter over f and the rest of the formatters.
+func newTeeFormatter[B buffer](f formatter[B], rest ...logger.Formatter) logger.Formatter {
+	fmtrs := make([]formatter[B], 0, 1+len(rest))
+	fmtrs = append(fmtrs, f)
+	for _, r := range rest {
+		fmtrs = append(fmtrs, any(r).(formatter[B]))
+	}
+	return asFormatter[B](teeFormatter[B]{fmtrs: fmtrs})
+}
+
+func (f teeFormatter[B]) writeFrame(buf B, i int) {
+	var hdr [3 + binary.MaxVarintLen64]byte
+	hdr[0], hdr[1] = frameMarker, frameSink
+	n := binary.PutUvarint(hdr[3:], uint64(i))
+	hdr[2] = byte(n)
+	buf.Write(hdr[:3+n])
+}
+
+func (f teeFormatter[B]) WriteMessage(buf B, ts time.Time, lvl logger.Level, msg string) {
+	for i, fmtr := range f.fmtrs {
+		f.writeFrame(buf, i)
+		fmtr.WriteMessage(buf, ts, lvl, msg)
+	}
+}
+
+func (f teeFormatter[B]) AppendCaller(buf B, frame runtime.Frame) {
+	for i, fmtr := range f.fmtrs {
+		f.writeFrame(buf, i)
+		appendCaller(fmtr, buf, frame)
+	}
+}
+
+func (f teeFormatter[B]) AppendBeginMarker(buf B) {
+	for i, fmtr := range f.fmtrs {
+		f.writeFrame(buf, i)
+		fmtr.AppendBeginMarker(buf)
+	}
+}
+
+func (f teeFormatter[B]) AppendEndMarker(buf B) {
+	for i, fmtr := range f.fmtrs {
+		f.writeFrame(buf, i)
+		fmtr.AppendEndMarker(buf)
+	}
+}
+
+func (f teeFormatter[B]) AppendLineBreak(buf B) {
+	for i, fmtr := range f.fmtrs {
+		f.writeFrame(buf, i)
+		fmtr.AppendLineBreak(buf)
+	}
+}
+
+func (f teeFormatter[B]) AppendArrayStart(buf B) {
+	for i, fmtr := range f.fmtrs {
+		f.writeFrame(buf, i)
+		fmtr.AppendArrayStart(buf)
+	}
+}
+
+func (f teeFormatter[B]) AppendArraySep(buf B) {
+	for i, fmtr := range f.fmtrs {
+		f.writeFrame(buf, i)
+		fmtr.AppendArraySep(buf)
+	}
+}
+
+func (f teeFormatter[B]) AppendArrayEnd(buf B) {
+	for i, fmtr := range f.fmtrs {
+		f.writeFrame(buf, i)
+		fmtr.AppendArrayEnd(buf)
+	}
+}
+
+func (f teeFormatter[B]) AppendKey(buf B, key string) {
+	for i, fmtr := range f.fmtrs {
+		f.writeFrame(buf, i)
+		fmtr.AppendKey(buf, key)
+	}
+}
+
+func (f teeFormatter[B]) AppendString(buf B, s string) {
+	for i, fmtr := range f.fmtrs {
+		f.writeFrame(buf, i)
+		fmtr.AppendString(buf, s)
+	}
+}
+
+func (f teeFormatter[B]) AppendBool(buf B, b bool) {
+	for i, fmtr := range f.fmtrs {
+		f.writeFrame(buf, i)
+		fmtr.AppendBool(buf, b)
+	}
+}
+
+func (f teeFormatter[B]) AppendInt(buf B, v int64) {
+	for i, fmtr := range f.fmtrs {
+		f.writeFrame(buf, i)
+		fmtr.AppendInt(buf, v)
+	}
+}
+
+func (f teeFormatter[B]) AppendUint(buf B, v uint64) {
+	for i, fmtr := range f.fmtrs {
+		f.writeFrame(buf, i)
+		fmtr.AppendUint(buf, v)
+	}
+}
+
+func (f teeFormatter[B]) AppendFloat(buf B, v float64) {
+	for i, fmtr := range f.fmtrs {
+		f.writeFrame(buf, i)
+		fmtr.AppendFloat(buf, v)
+	}
+}
+
+func (f teeFormatter[B]) AppendTime(buf B, t time.Time) {
+	for i, fmtr := range f.fmtrs {
+		f.writeFrame(buf, i)
+		fmtr.AppendTime(buf, t)
+	}
+}
+
+func (f teeFormatter[B]) AppendDuration(buf B, d time.Duration) {
+	for i, fmtr := range f.fmtrs {
+		f.writeFrame(buf, i)
+		fmtr.AppendDuration(buf, d)
+	}
+}
+
+func (f teeFormatter[B]) AppendInterface(buf B, v any) {
+	for i, fmtr := range f.fmtrs {
+		f.writeFrame(buf, i)
+		fmtr.AppendInterface(buf, v)
+	}
+}
@@ -0,0 +1,201 @@
+package cmd_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hamba/cmd/v3"
+	lctx "github.com/hamba/logger/v2/ctx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v3"
+)
+
+func TestNewLogger_Sinks(t *testing.T) {
+	dir := t.TempDir()
+	jsonFile := filepath.Join(dir, "app.json")
+	logfmtFile := filepath.Join(dir, "app.log")
+
+	tests := []struct {
+		name       string
+		args       []string
+		wantJSON   string
+		wantLogfmt string
+	}{
+		{
+			name: "formats and levels",
+			args: []string{
+				"--log.level=debug",
+				"--log.ctx=env=prod",
+				"--log.sink=file://" + jsonFile + "?format=json&level=debug",
+				"--log.sink=file://" + logfmtFile + "?level=info",
+			},
+			wantJSON: `{"lvl":"dbug","msg":"debug message","env":"prod","a":[1,2]}` + "\n" +
+				`{"lvl":"info","msg":"info message","env":"prod","component":"db","password":"secret"}` + "\n",
+			wantLogfmt: `lvl=info msg="info message" env=prod component=db password=secret` + "\n",
+		},
+		{
+			name: "log level applies to sinks",
+			args: []string{
+				"--log.level=info",
+				"--log.sink=file://" + jsonFile + "?format=json",
+				"--log.sink=file://" + logfmtFile,
+			},
+			wantJSON:   `{"lvl":"info","msg":"info message","component":"db","password":"secret"}` + "\n",
+			wantLogfmt: `lvl=info msg="info message" component=db password=secret` + "\n",
+		},
+		{
+			name: "component levels",
+			args: []string{
+				"--log.level=info,db=error",
+				"--log.sink=file://" + jsonFile + "?format=json",
+				"--log.sink=file://" + logfmtFile + "?format=logfmt",
+			},
+			wantJSON:   "",
+			wantLogfmt: "",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_ = os.Remove(jsonFile)
+			_ = os.Remove(logfmtFile)
+
+			c := &cli.Command{
+				Flags: cmd.LogFlags,
+				Action: func(_ context.Context, c *cli.Command) error {
+					w, err := cmd.NewLogWriter(c)
+					if err != nil {
+						return err
+					}
+					defer func() { _ = w.Close() }()
+
+					log, err := cmd.NewLoggerWithOptions(c, &cmd.LoggerOptions{Writer: w})
+					if err != nil {
+						return err
+					}
+
+					log.Debug("debug message", lctx.Ints("a", []int{1, 2}))
+					log.Info("info message", lctx.Str("component", "db"), lctx.Str("password", "secret"))
+					return nil
+				},
+			}
+
+			err := c.Run(t.Context(), append([]string{"test"}, test.args...))
+
+			require.NoError(t, err)
+			assert.Equal(t, test.wantJSON, readFile(t, jsonFile))
+			assert.Equal(t, test.wantLogfmt, readFile(t, logfmtFile))
+		})
+	}
+}
+
+func TestNewLogger_SinksRedacted(t *testing.T) {
+	dir := t.TempDir()
+	jsonFile := filepath.Join(dir, "app.json")
+	logfmtFile := filepath.Join(dir, "app.log")
+
+	c := &cli.Command{
+		Flags: cmd.LogFlags,
+		Action: func(_ context.Context, c *cli.Command) error {
+			w, err := cmd.NewLogWriter(c)
+			if err != nil {
+				return err
+			}
+			defer func() { _ = w.Close() }()
+
+			log, err := cmd.NewLoggerWithOptions(c, &cmd.LoggerOptions{Writer: w})
+			if err != nil {
+				return err
+			}
+
+			log.Info("message", lctx.Str("password", "secret"), lctx.Strs("tokens", []string{"a", "b"}), lctx.Str("user", "bob"))
+			return nil
+		},
+	}
+
+	err := c.Run(t.Context(), []string{
+		"test",
+		"--log.redact=password",
+		"--log.redact=tokens",
+		"--log.async",
+		"--log.sink=file://" + jsonFile + "?format=json",
+		"--log.sink=file://" + logfmtFile,
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, `{"lvl":"info","msg":"message","password":"[REDACTED]","tokens":"[REDACTED]","user":"bob"}`+"\n", readFile(t, jsonFile))
+	assert.Equal(t, "lvl=info msg=message password=[REDACTED] tokens=[REDACTED] user=bob\n", readFile(t, logfmtFile))
+}
+
+func TestNewLogWriter_SinkErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{
+			name: "unsupported destination",
+			args: []string{"--log.sink=unknown://"},
+		},
+		{
+			name: "unsupported format",
+			args: []string{"--log.sink=stdout?format=xml"},
+		},
+		{
+			name: "invalid level",
+			args: []string{"--log.sink=stdout?level=loud"},
+		},
+		{
+			name: "level more verbose than log level",
+			args: []string{"--log.level=info,db=debug", "--log.sink=stdout?level=trace"},
+		},
+		{
+			name: "unknown parameter",
+			args: []string{"--log.sink=stdout?colour=red"},
+		},
+		{
+			name: "missing file path",
+			args: []string{"--log.sink=file://"},
+		},
+		{
+			name: "sink and file",
+			args: []string{"--log.sink=stdout", "--log.file=test.log"},
+		},
+		{
+			name: "sink and output",
+			args: []string{"--log.sink=stdout", "--log.output=stderr"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := &cli.Command{
+				Flags: cmd.LogFlags,
+				Action: func(_ context.Context, c *cli.Command) error {
+					w, err := cmd.NewLogWriter(c)
+					if err != nil {
+						return err
+					}
+					return w.Close()
+				},
+			}
+
+			err := c.Run(t.Context(), append([]string{"test"}, test.args...))
+
+			assert.Error(t, err)
+		})
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return ""
+	}
+	require.NoError(t, err)
+	return string(b)
+}
@@ -0,0 +1,198 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hamba/logger/v2"
+)
+
+// syslogFacility is the user-level syslog facility.
+const syslogFacility = 1
+
+// syslogSDID is the structured data ID log context is sent under.
+const syslogSDID = "ctx@32473"
+
+// syslogWriter writes log lines to syslog in the RFC 5424 format,
+// sending the log context as structured data.
+type syslogWriter struct {
+	conn  *redialConn
+	hooks closeHooks
+
+	hostname string
+	app      string
+	sd       string
+}
+
+// newSyslogWriter returns a syslog writer for the given target,
+// either `syslog://unix:///dev/log` or `syslog+udp://host:514`.
+func newSyslogWriter(target, app string, tags map[string]string) (*syslogWriter, error) {
+	network, addr, err := parseSyslogTarget(target)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := dialRedial(network, addr)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to syslog: %w", err)
+	}
+
+	hostname, _ := os.Hostname()
+	return &syslogWriter{
+		conn:     conn,
+		hostname: syslogName(hostname, 255),
+		app:      syslogName(app, 48),
+		sd:       syslogStructuredData(tags),
+	}, nil
+}
+
+// parseSyslogTarget returns the network and address of the syslog target.
+func parseSyslogTarget(target string) (network, addr string, err error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return "", "", fmt.Errorf("parsing log output: %w", err)
+	}
+
+	switch u.Scheme {
+	case "syslog":
+		addr = "/dev/log"
+		if rest := strings.TrimPrefix(target, "syslog://"); rest != "" {
+			su, err := url.Parse(rest)
+			if err != nil {
+				return "", "", fmt.Errorf("parsing log output: %w", err)
+			}
+			if su.Scheme != "unix" || su.Path == "" {
+				return "", "", fmt.Errorf("unsupported syslog address %q", rest)
+			}
+			addr = su.Path
+		}
+		return "unixgram", addr, nil
+	case "syslog+udp":
+		if u.Host == "" {
+			return "", "", errors.New("syslog host is required")
+		}
+		addr = u.Host
+		if u.Port() == "" {
+			addr = net.JoinHostPort(u.Hostname(), "514")
+		}
+		return "udp", addr, nil
+	default:
+		return "", "", fmt.Errorf("unsupported log output %q", target)
+	}
+}
+
+// Write writes the line at info level.
+func (w *syslogWriter) Write(p []byte) (int, error) {
+	return w.writeLevel(logger.Info, p)
+}
+
+func (w *syslogWriter) writeLevel(lvl logger.Level, p []byte) (int, error) {
+	msg := make([]byte, 0, 128+len(w.sd)+len(p))
+	msg = append(msg, '<')
+	msg = strconv.AppendInt(msg, int64(syslogFacility*8+syslogSeverity(lvl)), 10)
+	msg = append(msg, ">1 "...)
+	msg = time.Now().AppendFormat(msg, time.RFC3339Nano)
+	msg = append(msg, ' ')
+	msg = append(msg, w.hostname...)
+	msg = append(msg, ' ')
+	msg = append(msg, w.app...)
+	msg = append(msg, ' ')
+	msg = strconv.AppendInt(msg, int64(os.Getpid()), 10)
+	msg = append(msg, " - "...)
+	msg = append(msg, w.sd...)
+	msg = append(msg, ' ')
+	msg = append(msg, trimNewline(p)...)
+
+	if _, err := w.conn.Write(msg); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (w *syslogWriter) closeHooks() *closeHooks { return &w.hooks }
+
+// Close closes the syslog connection.
+func (w *syslogWriter) Close() error {
+	w.hooks.run()
+	return w.conn.Close()
+}
+
+// syslogSeverity returns the syslog severity of the log level.
+func syslogSeverity(lvl logger.Level) int {
+	switch lvl {
+	case logger.Crit:
+		return 2
+	case logger.Error:
+		return 3
+	case logger.Warn:
+		return 4
+	case logger.Info:
+		return 6
+	default:
+		return 7
+	}
+}
+
+// syslogName returns s as a syslog header field, which must be printable ASCII.
+func syslogName(s string, maxLen int) string {
+	if s == "" {
+		return "-"
+	}
+	b := []byte(s)
+	for i, c := range b {
+		if c <= ' ' || c > '~' {
+			b[i] = '_'
+		}
+	}
+	if len(b) > maxLen {
+		b = b[:maxLen]
+	}
+	return string(b)
+}
+
+// syslogEscaper escapes structured data parameter values.
+var syslogEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+
+// syslogStructuredData returns the structured data element of the tags.
+func syslogStructuredData(tags map[string]string) string {
+	if len(tags) == 0 {
+		return "-"
+	}
+
+	var sb strings.Builder
+	sb.WriteString("[" + syslogSDID)
+	for _, k := range slices.Sorted(maps.Keys(tags)) {
+		name := []byte(k)
+		for i, c := range name {
+			if c <= ' ' || c > '~' || c == '=' || c == ']' || c == '"' {
+				name[i] = '_'
+			}
+		}
+		if len(name) > 32 {
+			name = name[:32]
+		}
+
+		sb.WriteByte(' ')
+		sb.Write(name)
+		sb.WriteString(`="`)
+		sb.WriteString(syslogEscaper.Replace(tags[k]))
+		sb.WriteByte('"')
+	}
+	sb.WriteByte(']')
+	return sb.String()
+}
+
+func trimNewline(p []byte) []byte {
+	if len(p) > 0 && p[len(p)-1] == '\n' {
+		return p[:len(p)-1]
+	}
+	return p
+}
@@ -0,0 +1,157 @@
+package cmd_test
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hamba/cmd/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v3"
+)
+
+func TestNewLogger_Syslog(t *testing.T) {
+	conn := listenUnixgram(t)
+
+	c := &cli.Command{
+		Name:  "app",
+		Flags: cmd.LogFlags,
+		Action: func(_ context.Context, c *cli.Command) error {
+			w, err := cmd.NewLogWriter(c)
+			if err != nil {
+				return err
+			}
+			defer func() { _ = w.Close() }()
+
+			log, err := cmd.NewLoggerWithOptions(c, &cmd.LoggerOptions{Writer: w})
+			if err != nil {
+				return err
+			}
+
+			log.Error("test message")
+			return nil
+		},
+	}
+
+	args := []string{"app", "--log.output=syslog://unix://" + conn.LocalAddr().String(), "--log.ctx=env=prod", "--log.ctx=quote=a\"b]"}
+	err := c.Run(t.Context(), args)
+
+	require.NoError(t, err)
+	got := readDatagram(t, conn)
+	hostname, _ := os.Hostname()
+	header := regexp.MustCompile(`^<11>1 \S+ ` + regexp.QuoteMeta(hostname) + ` app \d+ - \[ctx@32473 ((?:[^\]\\]|\\.)*)\] (.*)$`)
+	m := header.FindStringSubmatch(got)
+	require.Len(t, m, 3, got)
+	// The ctx fields are in map order, so they are compared as sets.
+	params := regexp.MustCompile(`\S+?="(?:[^"\\]|\\.)*"`).FindAllString(m[1], -1)
+	assert.ElementsMatch(t, []string{`env="prod"`, `quote="a\"b\]"`}, params)
+	msg, ok := strings.CutPrefix(m[2], `lvl=eror msg="test message" `)
+	require.True(t, ok, m[2])
+	assert.ElementsMatch(t, []string{`env=prod`, `quote="a\"b]"`}, strings.Fields(msg))
+}
+
+func TestNewLogger_SyslogUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	c := &cli.Command{
+		Name:  "app",
+		Flags: cmd.LogFlags,
+		Action: func(_ context.Context, c *cli.Command) error {
+			log, err := cmd.NewLogger(c)
+			if err != nil {
+				return err
+			}
+
+			log.Debug("test message")
+			return nil
+		},
+	}
+
+	err = c.Run(t.Context(), []string{"app", "--log.output=syslog+udp://" + conn.LocalAddr().String(), "--log.level=debug"})
+
+	require.NoError(t, err)
+	got := readDatagram(t, conn)
+	assert.Regexp(t, `^<15>1 \S+ \S+ app \d+ - - lvl=dbug msg="test message"$`, got)
+}
+
+func TestNewLogWriter_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{
+			name: "unsupported output",
+			args: []string{"--log.output=unknown://"},
+		},
+		{
+			name: "output and file",
+			args: []string{"--log.output=journald://", "--log.file=test.log"},
+		},
+		{
+			name: "unsupported syslog address",
+			args: []string{"--log.output=syslog://tcp://localhost:514"},
+		},
+		{
+			name: "missing syslog host",
+			args: []string{"--log.output=syslog+udp://"},
+		},
+		{
+			name: "missing syslog socket",
+			args: []string{"--log.output=syslog://unix://" + filepath.Join(t.TempDir(), "missing.sock")},
+		},
+		{
+			name: "unsupported journald address",
+			args: []string{"--log.output=journald://host"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := &cli.Command{
+				Flags: cmd.LogFlags,
+				Action: func(_ context.Context, c *cli.Command) error {
+					w, err := cmd.NewLogWriter(c)
+					if err != nil {
+						return err
+					}
+					return w.Close()
+				},
+			}
+
+			err := c.Run(t.Context(), append([]string{"test"}, test.args...))
+
+			assert.Error(t, err)
+		})
+	}
+}
+
+func listenUnixgram(t *testing.T) net.PacketConn {
+	t.Helper()
+
+	dir, err := os.MkdirTemp("", "log")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+
+	conn, err := net.ListenPacket("unixgram", filepath.Join(dir, "log.sock"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+func readDatagram(t *testing.T, conn net.PacketConn) string {
+	t.Helper()
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	buf := make([]byte, 4096)
+	n, _, err := conn.ReadFrom(buf)
+	require.NoError(t, err)
+	return string(buf[:n])
+}
@@ -2,14 +2,21 @@ package cmd_test
 
 import (
 	"context"
+	"errors"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/hamba/cmd/v3"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/urfave/cli/v3"
 )
 
 func TestNewLogger(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "test.log")
+
 	tests := []struct {
 		name    string
 		args    []string
@@ -38,7 +45,7 @@ func TestNewLogger(t *testing.T) {
 		{
 			name:    "invalid format",
 			args:    []string{"--log.level=info", "--log.format=invalid"},
-			wantErr: assert.NoError,
+			wantErr: assert.Error,
 		},
 		{
 			name:    "valid level",
@@ -50,6 +57,21 @@ func TestNewLogger(t *testing.T) {
 			args:    []string{"--log.level=invalid", "--log.format=json"},
 			wantErr: assert.Error,
 		},
+		{
+			name:    "component levels",
+			args:    []string{"--log.level=info,db=debug,http=warn"},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "only component levels",
+			args:    []string{"--log.level=db=debug"},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "invalid component level",
+			args:    []string{"--log.level=info,db=invalid"},
+			wantErr: assert.Error,
+		},
 		{
 			name:    "tags",
 			args:    []string{"--log.level=info", "--log.format=json", "--log.ctx=a=b"},
@@ -60,6 +82,56 @@ func TestNewLogger(t *testing.T) {
 			args:    []string{"--log.level=info", "--log.format=json", "--log.ctx=a"},
 			wantErr: assert.Error,
 		},
+		{
+			name:    "log exporter without provider",
+			args:    []string{"--log.exporter=otlphttp", "--log.endpoint=localhost:4318"},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "unknown log exporter",
+			args:    []string{"--log.exporter=some-exporter"},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "file",
+			args:    []string{"--log.file=" + file, "--log.max-size=10", "--log.rotate-interval=24h", "--log.max-age=72h", "--log.max-backups=3", "--log.compress"},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "invalid file max size",
+			args:    []string{"--log.file=" + file, "--log.max-size=-1"},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "invalid file rotate interval",
+			args:    []string{"--log.file=" + file, "--log.rotate-interval=-1h"},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "invalid file max age",
+			args:    []string{"--log.file=" + file, "--log.max-age=-1h"},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "invalid file max backups",
+			args:    []string{"--log.file=" + file, "--log.max-backups=-1"},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "async",
+			args:    []string{"--log.async", "--log.async-queue-size=10", "--log.async-policy=drop"},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "invalid async queue size",
+			args:    []string{"--log.async", "--log.async-queue-size=0"},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "invalid async policy",
+			args:    []string{"--log.async", "--log.async-policy=invalid"},
+			wantErr: assert.Error,
+		},
 	}
 
 	for _, test := range tests {
@@ -78,3 +150,108 @@ func TestNewLogger(t *testing.T) {
 		})
 	}
 }
+
+func TestNewLogWriter(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "test.log")
+
+	c := &cli.Command{
+		Flags: cmd.LogFlags,
+		Action: func(_ context.Context, c *cli.Command) error {
+			w, err := cmd.NewLogWriter(c)
+			if err != nil {
+				return err
+			}
+			defer func() { _ = w.Close() }()
+
+			log, err := cmd.NewLoggerWithOptions(c, &cmd.LoggerOptions{Writer: w})
+			if err != nil {
+				return err
+			}
+
+			log.Info("test message")
+			return nil
+		},
+	}
+
+	err := c.Run(t.Context(), []string{"test", "--log.file=" + file, "--log.format=logfmt"})
+
+	require.NoError(t, err)
+	got, err := os.ReadFile(file)
+	require.NoError(t, err)
+	assert.Equal(t, "lvl=info msg=\"test message\"\n", string(got))
+}
+
+func TestNewLogWriter_Async(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "test.log")
+
+	c := &cli.Command{
+		Flags: cmd.LogFlags,
+		Action: func(_ context.Context, c *cli.Command) error {
+			w, err := cmd.NewLogWriter(c)
+			if err != nil {
+				return err
+			}
+			defer func() { _ = w.Close() }()
+
+			if _, ok := w.(*cmd.AsyncWriter); !ok {
+				return errors.New("expected async writer")
+			}
+
+			log, err := cmd.NewLoggerWithOptions(c, &cmd.LoggerOptions{Writer: w})
+			if err != nil {
+				return err
+			}
+
+			log.Info("test message")
+			return nil
+		},
+	}
+
+	err := c.Run(t.Context(), []string{"test", "--log.file=" + file, "--log.format=logfmt", "--log.async"})
+
+	require.NoError(t, err)
+	got, err := os.ReadFile(file)
+	require.NoError(t, err)
+	assert.Equal(t, "lvl=info msg=\"test message\"\n", string(got))
+}
+
+func TestNewLogWriter_RotateInterval(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "test.log")
+
+	c := &cli.Command{
+		Flags: cmd.LogFlags,
+		Action: func(_ context.Context, c *cli.Command) error {
+			w, err := cmd.NewLogWriter(c)
+			if err != nil {
+				return err
+			}
+			defer func() { _ = w.Close() }()
+
+			_, err = w.Write([]byte("rotated\n"))
+			if err != nil {
+				return err
+			}
+
+			assert.EventuallyWithT(t, func(c *assert.CollectT) {
+				got, err := os.ReadFile(file)
+				require.NoError(c, err)
+				assert.Empty(c, got)
+			}, time.Second, 5*time.Millisecond)
+			return nil
+		},
+	}
+
+	err := c.Run(t.Context(), []string{"test", "--log.file=" + file, "--log.rotate-interval=10ms"})
+
+	require.NoError(t, err)
+	backups, err := filepath.Glob(filepath.Join(dir, "test-*.log"))
+	require.NoError(t, err)
+	var got []byte
+	for _, backup := range backups {
+		b, err := os.ReadFile(backup)
+		require.NoError(t, err)
+		got = append(got, b...)
+	}
+	assert.Equal(t, "rotated\n", string(got))
+}
@@ -0,0 +1,74 @@
+package observe
+
+import (
+	"context"
+	"log"
+	"log/slog"
+	"os"
+
+	"github.com/go-logr/logr"
+	"github.com/go-logr/stdr"
+	"go.opentelemetry.io/otel"
+)
+
+// captureLogs redirects the standard library logger, the slog default
+// logger and the OTel global logger to the given logger.
+func captureLogs(l *slog.Logger) (restore func()) {
+	prevSlog := slog.Default()
+	prevOut, prevFlags, prevPrefix := log.Writer(), log.Flags(), log.Prefix()
+
+	slog.SetDefault(l)
+	otel.SetLogger(logr.New(otelLogSink{log: l}))
+
+	return func() {
+		// OTel does not expose its global logger, so its default is restored.
+		otel.SetLogger(stdr.New(log.New(os.Stderr, "", log.LstdFlags|log.Lshortfile)))
+		slog.SetDefault(prevSlog)
+		log.SetOutput(prevOut)
+		log.SetFlags(prevFlags)
+		log.SetPrefix(prevPrefix)
+	}
+}
+
+// otelLogSink is a logr sink that maps the OTel verbosity levels
+// to log levels.
+type otelLogSink struct {
+	log *slog.Logger
+}
+
+func (s otelLogSink) Init(logr.RuntimeInfo) {}
+
+func (s otelLogSink) Enabled(level int) bool {
+	return s.log.Enabled(context.Background(), otelSlogLevel(level))
+}
+
+func (s otelLogSink) Info(level int, msg string, keysAndValues ...any) {
+	s.log.Log(context.Background(), otelSlogLevel(level), msg, keysAndValues...)
+}
+
+func (s otelLogSink) Error(err error, msg string, keysAndValues ...any) {
+	s.log.Error(msg, append(keysAndValues, "error", err)...)
+}
+
+func (s otelLogSink) WithValues(keysAndValues ...any) logr.LogSink {
+	return otelLogSink{log: s.log.With(keysAndValues...)}
+}
+
+func (s otelLogSink) WithName(name string) logr.LogSink {
+	return otelLogSink{log: s.log.With("logger", name)}
+}
+
+// otelSlogLevel returns the slog level of the OTel verbosity level,
+// where warnings are logged at V(1), info at V(4) and debug at V(8).
+func otelSlogLevel(level int) slog.Level {
+	switch {
+	case level <= 1:
+		return slog.LevelWarn
+	case level <= 4:
+		return slog.LevelInfo
+	case level <= 8:
+		return slog.LevelDebug
+	default:
+		return slog.LevelDebug - 1
+	}
+}
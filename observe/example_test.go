@@ -21,3 +21,40 @@ func ExampleNew() {
 
 	_ = obsrv
 }
+
+func ExampleObserver_Slog() {
+	var (
+		ctx    context.Context
+		cliCmd *cli.Command // Get this from your action
+	)
+
+	obsrv, err := observe.New(ctx, cliCmd, "my-service", &observe.Options{})
+	if err != nil {
+		// Handle error.
+		return
+	}
+	defer obsrv.Close()
+
+	log := obsrv.Slog()
+
+	_ = log
+}
+
+func ExampleObserver_LogFromContext() {
+	var (
+		ctx    context.Context
+		cliCmd *cli.Command // Get this from your action
+	)
+
+	obsrv, err := observe.New(ctx, cliCmd, "my-service", &observe.Options{})
+	if err != nil {
+		// Handle error.
+		return
+	}
+	defer obsrv.Close()
+
+	ctx, span := obsrv.Tracer("my-tracer").Start(ctx, "my-span")
+	defer span.End()
+
+	obsrv.LogFromContext(ctx).Info("Logged with the trace and span IDs")
+}
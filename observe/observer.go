@@ -3,6 +3,7 @@ package observe
 import (
 	"context"
 	"io"
+	"log/slog"
 	"time"
 
 	otelpyroscope "github.com/grafana/otel-profiling-go"
@@ -20,23 +21,46 @@ import (
 )
 
 // Options optionally configures an observer.
+//
+// The log time, timestamps and runtime stats options can also be set with
+// flags. The log time format and runtime stats interval set here take
+// precedence over the flags. Log timestamps and runtime stats are enabled
+// when enabled either here or by their flag, so a flag cannot turn off
+// what is enabled here.
 type Options struct {
 	LogTimeFormat string
 	LogTimestamps bool
 	LogCtx        []logger.Field
 	LogWriter     io.Writer
+	// LogLevelDynamic allows the log level to be changed at runtime through
+	// Observer.LogLevel. As the level is then checked by the formatter rather
+	// than the logger, logging below the level is slightly more expensive. It
+	// is implied by LogLevelSignals.
+	LogLevelDynamic bool
+	LogLevelSignals bool
+	// CaptureLogs redirects the standard library logger, the slog default
+	// logger and the OTel global logger to the observer logger until it is closed.
+	CaptureLogs bool
 
 	StatsRuntime bool
-	StatsTags    []statter.Tag
+	// StatsRuntimeInterval is the frequency at which the runtime
+	// stats are collected, when collected.
+	StatsRuntimeInterval time.Duration
+	StatsTags            []statter.Tag
 
 	TracingAttrs []attribute.KeyValue
 }
 
 // Observer contains observability primitives.
 type Observer struct {
-	Log       *logger.Logger
+	Log *logger.Logger
+	// LogLevel changes the log level at runtime. It is nil unless
+	// LogLevelDynamic or LogLevelSignals is set in the options.
+	LogLevel  *cmd.LogLevel
 	Stats     *statter.Statter
 	TraceProv trace.TracerProvider
+	// Admin is the admin server, or nil when no admin address is configured.
+	Admin *cmd.AdminServer
 
 	closeFns []func()
 }
@@ -49,41 +73,85 @@ func New(ctx context.Context, cliCmd *cli.Command, svc string, opts *Options) (*
 		opts = &Options{}
 	}
 
+	if _, err := cmd.ValidateFlags(ctx, cliCmd); err != nil {
+		return nil, err
+	}
+
 	// Logger.
-	log, err := cmd.NewLoggerWithOptions(cliCmd, &cmd.LoggerOptions{Writer: opts.LogWriter})
+	w := opts.LogWriter
+	if w == nil {
+		logW, err := cmd.NewLogWriter(cliCmd)
+		if err != nil {
+			return nil, err
+		}
+		closeFns = append(closeFns, func() { _ = logW.Close() })
+		w = logW
+	}
+	opts.LogCtx = append([]logger.Field{lctx.Str("svc", svc)}, opts.LogCtx...)
+	logOpts := &cmd.LoggerOptions{Writer: w, Ctx: opts.LogCtx}
+	logProv, err := cmd.NewLogProvider(ctx, cliCmd, semconv.ServiceNameKey.String(svc))
+	if err != nil {
+		closeAll(closeFns)
+		return nil, err
+	}
+	if logProv != nil {
+		closeFns = append(closeFns, func() { _ = logProv.Shutdown(context.WithoutCancel(ctx)) })
+		logOpts.Provider = logProv
+	}
+	var (
+		log    *logger.Logger
+		logLvl *cmd.LogLevel
+	)
+	if opts.LogLevelDynamic || opts.LogLevelSignals {
+		log, logLvl, err = cmd.NewLoggerWithLevel(cliCmd, logOpts)
+	} else {
+		log, err = cmd.NewLoggerWithOptions(cliCmd, logOpts)
+	}
 	if err != nil {
+		closeAll(closeFns)
 		return nil, err
 	}
+	if opts.LogLevelSignals {
+		closeFns = append(closeFns, logLvl.HandleSignals())
+	}
 	if opts.LogTimeFormat != "" {
 		logger.TimeFormat = opts.LogTimeFormat
+	} else if cliCmd.IsSet(cmd.FlagLogTimeFormat) {
+		logger.TimeFormat = cmd.LogTimeFormat(cliCmd)
 	}
-	if opts.LogTimestamps {
+	if opts.LogTimestamps || cliCmd.Bool(cmd.FlagLogTimestamps) {
 		closeFns = append(closeFns, log.WithTimestamp())
 	}
-	opts.LogCtx = append([]logger.Field{lctx.Str("svc", svc)}, opts.LogCtx...)
-	log = log.With(opts.LogCtx...)
+	if opts.CaptureLogs {
+		closeFns = append(closeFns, captureLogs(slog.New(cmd.NewSlogHandler(log, logLvl))))
+	}
 
 	// Statter.
-	stats, err := cmd.NewStatter(cliCmd, log)
+	stats, metrics, err := cmd.NewStatterWithHandler(cliCmd, log, semconv.ServiceNameKey.String(svc))
 	if err != nil {
-		for _, fn := range closeFns {
-			fn()
-		}
+		closeAll(closeFns)
 		return nil, err
 	}
 	closeFns = append(closeFns, func() { _ = stats.Close() })
-	if opts.StatsRuntime {
-		go runtime.Collect(stats)
+	if opts.StatsRuntime || cliCmd.Bool(cmd.FlagStatsRuntime) {
+		intv := opts.StatsRuntimeInterval
+		if intv <= 0 {
+			intv = cliCmd.Duration(cmd.FlagStatsRuntimeInterval)
+		}
+		runtimeCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		go runtime.CollectWithContext(runtimeCtx, stats, intv)
+		closeFns = append(closeFns, cancel)
 	}
 	opts.StatsTags = append([]statter.Tag{tags.Str("svc", svc)}, opts.StatsTags...)
 	stats = stats.With("", opts.StatsTags...)
+	if aw, ok := w.(*cmd.AsyncWriter); ok {
+		aw.SetStatter(stats)
+	}
 
 	// Profiler.
 	prof, err := cmd.NewProfiler(cliCmd, svc, log)
 	if err != nil {
-		for _, fn := range closeFns {
-			fn()
-		}
+		closeAll(closeFns)
 		return nil, err
 	}
 	if prof != nil {
@@ -94,9 +162,7 @@ func New(ctx context.Context, cliCmd *cli.Command, svc string, opts *Options) (*
 	opts.TracingAttrs = append(opts.TracingAttrs, semconv.ServiceNameKey.String(svc))
 	tracer, err := cmd.NewTracer(ctx, cliCmd, log, opts.TracingAttrs...)
 	if err != nil {
-		for _, fn := range closeFns {
-			fn()
-		}
+		closeAll(closeFns)
 		return nil, err
 	}
 	closeFns = append(closeFns, func() { _ = tracer.Shutdown(context.WithoutCancel(ctx)) })
@@ -106,10 +172,26 @@ func New(ctx context.Context, cliCmd *cli.Command, svc string, opts *Options) (*
 		tp = otelpyroscope.NewTracerProvider(tp)
 	}
 
+	// Admin server.
+	adminOpts := &cmd.AdminOptions{Metrics: metrics}
+	if logLvl != nil {
+		adminOpts.LogLevel = logLvl
+	}
+	admin, err := cmd.NewAdminServer(cliCmd, svc, log, adminOpts)
+	if err != nil {
+		closeAll(closeFns)
+		return nil, err
+	}
+	if admin != nil {
+		closeFns = append(closeFns, func() { _ = admin.Close() })
+	}
+
 	return &Observer{
 		Log:       log,
+		LogLevel:  logLvl,
 		Stats:     stats,
 		TraceProv: tp,
+		Admin:     admin,
 		closeFns:  closeFns,
 	}, nil
 }
@@ -123,10 +205,32 @@ func (o *Observer) Tracer(name string, opts ...trace.TracerOption) trace.Tracer
 	return o.TraceProv.Tracer(name, opts...)
 }
 
+// LogFromContext returns the logger extended with the fields attached to the context,
+// along with the trace and span IDs of the span in the context.
+func (o *Observer) LogFromContext(ctx context.Context) *logger.Logger {
+	log := o.Log.FromContext(ctx)
+	if fields := cmd.TraceFields(ctx); len(fields) > 0 {
+		log = log.With(fields...)
+	}
+	return log
+}
+
+// Slog returns a slog logger that writes to the observer logger.
+// Records logged with a context carry the trace and span IDs of the span in the context.
+func (o *Observer) Slog() *slog.Logger {
+	return slog.New(cmd.NewSlogHandler(o.Log, o.LogLevel))
+}
+
 // Close closes the observability primitives.
 func (o *Observer) Close() {
-	for _, fn := range o.closeFns {
-		fn()
+	closeAll(o.closeFns)
+}
+
+// closeAll calls the close functions in reverse order, so that
+// primitives are closed before the primitives they depend on.
+func closeAll(fns []func()) {
+	for i := len(fns) - 1; i >= 0; i-- {
+		fns[i]()
 	}
 }
 
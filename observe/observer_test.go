@@ -0,0 +1,219 @@
+package observe_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hamba/cmd/v3"
+	"github.com/hamba/cmd/v3/observe"
+	"github.com/hamba/logger/v2"
+	lctx "github.com/hamba/logger/v2/ctx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v3"
+	"go.opentelemetry.io/otel"
+)
+
+func TestObserver_LogFromContext(t *testing.T) {
+	var buf bytes.Buffer
+	obsrv := newObserver(t, &observe.Options{LogWriter: &buf}, "--log.format=logfmt")
+
+	ctx, span := obsrv.Tracer("test").Start(t.Context(), "test")
+	defer span.End()
+	ctx = logger.WithContext(ctx, obsrv.Log, lctx.Str("req", "123"))
+
+	obsrv.LogFromContext(ctx).Info("in span")
+	obsrv.LogFromContext(t.Context()).Info("no span")
+
+	sc := span.SpanContext()
+	want := "lvl=info msg=\"in span\" svc=my-service req=123 trace_id=" + sc.TraceID().String() + " span_id=" + sc.SpanID().String() + "\n" +
+		"lvl=info msg=\"no span\" svc=my-service\n"
+	assert.Equal(t, want, buf.String())
+}
+
+func TestObserver_Slog(t *testing.T) {
+	var buf bytes.Buffer
+	obsrv := newObserver(t, &observe.Options{LogWriter: &buf}, "--log.format=logfmt", "--log.level=warn")
+
+	obsrv.Slog().Info("dropped")
+	obsrv.Slog().Warn("kept", "a", "b")
+
+	assert.Equal(t, "lvl=warn msg=kept svc=my-service a=b\n", buf.String())
+}
+
+func TestObserver_CaptureLogs(t *testing.T) {
+	var buf bytes.Buffer
+	obsrv := newObserver(t, &observe.Options{LogWriter: &buf, CaptureLogs: true}, "--log.format=logfmt", "--log.level=debug")
+
+	log.Printf("std log %d", 1)
+	slog.Debug("slog", "a", "b")
+	otel.Handle(errors.New("test error"))
+
+	got := buf.String()
+	assert.Contains(t, got, "lvl=info msg=\"TracerProvider created\" svc=my-service")
+	assert.Contains(t, got, "lvl=info msg=\"std log 1\" svc=my-service\n")
+	assert.Contains(t, got, "lvl=dbug msg=slog svc=my-service a=b\n")
+	assert.Contains(t, got, "lvl=eror msg=\"test error\" svc=my-service component=otel\n")
+
+	obsrv.Close()
+	buf.Reset()
+	log.Printf("after close")
+	slog.Info("after close")
+
+	assert.Empty(t, buf.String())
+}
+
+func TestNew_ValidatesFlags(t *testing.T) {
+	c := &cli.Command{
+		Flags: cmd.MonitoringFlags,
+		Action: func(ctx context.Context, c *cli.Command) error {
+			_, err := observe.New(ctx, c, "my-service", nil)
+			return err
+		},
+	}
+
+	err := c.Run(t.Context(), []string{"test", "--log.format=xml", "--tracing.ratio=2"})
+
+	var flagErr *cmd.FlagError
+	require.ErrorAs(t, err, &flagErr)
+	assert.ErrorContains(t, err, "--log.format")
+	assert.ErrorContains(t, err, "--tracing.ratio")
+}
+
+func newObserver(t *testing.T, opts *observe.Options, args ...string) *observe.Observer {
+	t.Helper()
+
+	var obsrv *observe.Observer
+	c := &cli.Command{
+		Flags: cmd.MonitoringFlags,
+		Action: func(ctx context.Context, c *cli.Command) error {
+			var err error
+			obsrv, err = observe.New(ctx, c, "my-service", opts)
+			return err
+		},
+	}
+
+	err := c.Run(t.Context(), append([]string{"test"}, args...))
+	require.NoError(t, err)
+	t.Cleanup(obsrv.Close)
+
+	return obsrv
+}
+
+func TestObserver_LogTimeFlags(t *testing.T) {
+	t.Cleanup(func() { logger.TimeFormat = logger.TimeFormatUnix })
+
+	var buf bytes.Buffer
+	obsrv := newObserver(t, &observe.Options{LogWriter: &buf}, "--log.format=logfmt", "--log.timestamps", "--log.time-format=iso8601")
+
+	obsrv.Log.Info("test")
+
+	assert.Equal(t, logger.TimeFormatISO8601, logger.TimeFormat)
+	assert.Regexp(t, `^ts=\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}[+-]\d{4} lvl=info msg=test svc=my-service\n$`, buf.String())
+}
+
+func TestObserver_LogTimeFormatOptionTakesPrecedence(t *testing.T) {
+	t.Cleanup(func() { logger.TimeFormat = logger.TimeFormatUnix })
+
+	var buf bytes.Buffer
+	opts := &observe.Options{LogWriter: &buf, LogTimeFormat: "15:04", LogTimestamps: true}
+	obsrv := newObserver(t, opts, "--log.format=logfmt", "--log.time-format=rfc3339")
+
+	obsrv.Log.Info("test")
+
+	assert.Equal(t, "15:04", logger.TimeFormat)
+	assert.Regexp(t, `^ts=\d{2}:\d{2} lvl=info msg=test svc=my-service\n$`, buf.String())
+}
+
+func TestObserver_StatsRuntimeFlags(t *testing.T) {
+	var buf bytes.Buffer
+	obsrv := newObserver(t, &observe.Options{LogWriter: &buf},
+		"--stats.dsn=prometheus://",
+		"--stats.interval=10ms",
+		"--stats.runtime",
+		"--stats.runtime-interval=10ms",
+		"--admin.addr=127.0.0.1:0",
+	)
+
+	assert.EventuallyWithT(t, func(c *assert.CollectT) {
+		resp, err := http.Get("http://" + obsrv.Admin.Addr().String() + "/metrics")
+		if !assert.NoError(c, err) {
+			return
+		}
+		defer func() { _ = resp.Body.Close() }()
+
+		b, err := io.ReadAll(resp.Body)
+		assert.NoError(c, err)
+		assert.Contains(c, string(b), "runtime_cpu_goroutines ")
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestObserver_Admin(t *testing.T) {
+	var buf bytes.Buffer
+	obsrv := newObserver(t, &observe.Options{LogWriter: &buf}, "--stats.dsn=prometheus://", "--admin.addr=127.0.0.1:0")
+
+	require.NotNil(t, obsrv.Admin)
+	addr := obsrv.Admin.Addr().String()
+
+	resp, err := http.Get("http://" + addr + "/metrics")
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	obsrv.Close()
+
+	_, err = http.Get("http://" + addr + "/healthz")
+	assert.Error(t, err)
+}
+
+func TestObserver_AdminLogLevel(t *testing.T) {
+	var buf bytes.Buffer
+	obsrv := newObserver(t, &observe.Options{LogWriter: &buf, LogLevelDynamic: true}, "--admin.addr=127.0.0.1:0")
+
+	req, err := http.NewRequest(http.MethodPut, "http://"+obsrv.Admin.Addr().String()+"/loglevel", strings.NewReader(`{"level":"debug"}`))
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, logger.Debug, obsrv.LogLevel.Level())
+}
+
+func TestObserver_NoAdmin(t *testing.T) {
+	var buf bytes.Buffer
+	obsrv := newObserver(t, &observe.Options{LogWriter: &buf})
+
+	assert.Nil(t, obsrv.Admin)
+}
+
+func TestObserver_LogLevel(t *testing.T) {
+	var buf bytes.Buffer
+	obsrv := newObserver(t, &observe.Options{LogWriter: &buf, LogLevelDynamic: true}, "--log.format=logfmt")
+
+	obsrv.Log.Debug("dropped")
+	obsrv.LogLevel.SetLevel(logger.Debug)
+	obsrv.Log.Debug("kept")
+
+	want := "lvl=info msg=\"Log level changed\" svc=my-service from=info to=debug\n" +
+		"lvl=dbug msg=kept svc=my-service\n"
+	assert.Equal(t, want, buf.String())
+}
+
+func TestObserver_StaticLogLevel(t *testing.T) {
+	var buf bytes.Buffer
+	obsrv := newObserver(t, &observe.Options{LogWriter: &buf}, "--log.format=logfmt")
+
+	obsrv.Log.Debug("dropped")
+
+	assert.Nil(t, obsrv.LogLevel)
+	assert.Empty(t, buf.String())
+}
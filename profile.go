@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
+	"slices"
 	"time"
 
 	"github.com/ettle/strcase"
@@ -75,9 +76,50 @@ func NewProfiler(cmd *cli.Command, svc string, log *logger.Logger) (*pyroscope.P
 		return nil, nil
 	}
 
+	prof, err := parseProfilingDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	types, err := parseProfilingTypes(cmd.StringSlice(FlagProfilingTypes))
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := pyroscope.Config{
+		ApplicationName:   svc,
+		Tags:              cmd.StringMap(FlagProfilingTags),
+		ServerAddress:     prof.addr,
+		AuthToken:         prof.token,
+		BasicAuthUser:     prof.username,
+		BasicAuthPassword: prof.password,
+		TenantID:          prof.tenantID,
+		UploadRate:        cmd.Duration(FlagProfileUploadRate),
+		Logger:            pyroLogAdapter{log: log},
+		ProfileTypes:      types,
+	}
+
+	return pyroscope.Start(cfg)
+}
+
+type profilingDSN struct {
+	addr     string
+	token    string
+	username string
+	password string
+	tenantID string
+}
+
+func parseProfilingDSN(dsn string) (profilingDSN, error) {
 	u, err := url.Parse(dsn)
 	if err != nil {
-		return nil, fmt.Errorf("parsing profiling DSN: %w", err)
+		return profilingDSN{}, fmt.Errorf("parsing profiling DSN: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return profilingDSN{}, fmt.Errorf("unsupported profiling DSN scheme %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return profilingDSN{}, errors.New("profiling DSN host is required")
 	}
 
 	tenantID := u.Query().Get("tenantid")
@@ -89,7 +131,7 @@ func NewProfiler(cmd *cli.Command, svc string, log *logger.Logger) (*pyroscope.P
 		password, _ = u.User.Password()
 	}
 	if (username != "" || password != "") && authToken != "" {
-		return nil, errors.New("cannot set auth token and basic auth")
+		return profilingDSN{}, errors.New("cannot set auth token and basic auth")
 	}
 
 	srvURL := &url.URL{
@@ -98,28 +140,29 @@ func NewProfiler(cmd *cli.Command, svc string, log *logger.Logger) (*pyroscope.P
 		Path:   u.Path,
 	}
 
-	types := allProfilingTypes
-	if newTypes := cmd.StringSlice(FlagProfilingTypes); len(newTypes) > 0 {
-		types = make([]pyroscope.ProfileType, len(newTypes))
-		for i, typ := range newTypes {
-			types[i] = pyroscope.ProfileType(typ)
-		}
-	}
+	return profilingDSN{
+		addr:     srvURL.String(),
+		token:    authToken,
+		username: username,
+		password: password,
+		tenantID: tenantID,
+	}, nil
+}
 
-	cfg := pyroscope.Config{
-		ApplicationName:   svc,
-		Tags:              cmd.StringMap(FlagProfilingTags),
-		ServerAddress:     srvURL.String(),
-		AuthToken:         authToken,
-		BasicAuthUser:     username,
-		BasicAuthPassword: password,
-		TenantID:          tenantID,
-		UploadRate:        cmd.Duration(FlagProfileUploadRate),
-		Logger:            pyroLogAdapter{log: log},
-		ProfileTypes:      types,
+func parseProfilingTypes(strs []string) ([]pyroscope.ProfileType, error) {
+	if len(strs) == 0 {
+		return allProfilingTypes, nil
 	}
 
-	return pyroscope.Start(cfg)
+	types := make([]pyroscope.ProfileType, len(strs))
+	for i, str := range strs {
+		typ := pyroscope.ProfileType(str)
+		if !slices.Contains(allProfilingTypes, typ) {
+			return nil, fmt.Errorf("unsupported profile type %q", str)
+		}
+		types[i] = typ
+	}
+	return types, nil
 }
 
 type pyroLogAdapter struct {
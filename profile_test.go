@@ -54,6 +54,15 @@ func TestNewProfiler(t *testing.T) {
 			},
 			wantErr: assert.Error,
 		},
+		{
+			name: "handles unsupported types",
+			args: []string{
+				"--profiling.dsn=https://example.com?token=test&tenantid=me",
+				"--profiling.types=cpu",
+				"--profiling.types=disk",
+			},
+			wantErr: assert.Error,
+		},
 		{
 			name: "handles basic and token auth",
 			args: []string{
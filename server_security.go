@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// serverSecurity configures the TLS and authentication of a server.
+type serverSecurity struct {
+	certFile     string
+	keyFile      string
+	clientCAFile string
+
+	user     string
+	password string
+	token    string
+}
+
+func (s serverSecurity) validate() error {
+	if (s.certFile == "") != (s.keyFile == "") {
+		return errors.New("tls requires both a cert and a key")
+	}
+	if s.clientCAFile != "" && s.certFile == "" {
+		return errors.New("tls client ca requires a cert and a key")
+	}
+	return nil
+}
+
+// tlsConfig returns the TLS config of the server, requiring and verifying
+// client certificates when a client CA is set. The key pair is reloaded when
+// its files change, so certificates can be rotated without a restart.
+// If TLS is not configured, nil is returned.
+func (s serverSecurity) tlsConfig() (*tls.Config, error) {
+	if s.certFile == "" {
+		//nolint:nilnil // There is no sentinel in this case.
+		return nil, nil
+	}
+
+	certs := &certLoader{certFile: s.certFile, keyFile: s.keyFile}
+	if _, err := certs.getCertificate(nil); err != nil {
+		return nil, err
+	}
+	cfg := &tls.Config{
+		GetCertificate: certs.getCertificate,
+		MinVersion:     tls.VersionTLS12,
+	}
+
+	if s.clientCAFile != "" {
+		b, err := os.ReadFile(s.clientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading tls client ca: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("loading tls client ca: no certificates found in %s", s.clientCAFile)
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return cfg, nil
+}
+
+// certLoader loads a TLS key pair, reloading it when the
+// modification time of either of its files changes.
+type certLoader struct {
+	certFile string
+	keyFile  string
+
+	mu       sync.Mutex
+	cert     *tls.Certificate
+	certTime time.Time
+	keyTime  time.Time
+}
+
+// getCertificate returns the key pair, reloading it if its files changed. While
+// the files cannot be loaded, e.g. during a rotation, the last pair is returned.
+func (l *certLoader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	certTime, keyTime, err := l.modTimes()
+	if err != nil {
+		return l.last(err)
+	}
+	if l.cert != nil && certTime.Equal(l.certTime) && keyTime.Equal(l.keyTime) {
+		return l.cert, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(l.certFile, l.keyFile)
+	if err != nil {
+		return l.last(err)
+	}
+	l.cert, l.certTime, l.keyTime = &cert, certTime, keyTime
+	return l.cert, nil
+}
+
+// last returns the last loaded key pair, or the error if none was loaded.
+func (l *certLoader) last(err error) (*tls.Certificate, error) {
+	if l.cert == nil {
+		return nil, fmt.Errorf("loading tls cert: %w", err)
+	}
+	return l.cert, nil
+}
+
+func (l *certLoader) modTimes() (certTime, keyTime time.Time, err error) {
+	certInfo, err := os.Stat(l.certFile)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	keyInfo, err := os.Stat(l.keyFile)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	return certInfo.ModTime(), keyInfo.ModTime(), nil
+}
+
+// authenticate wraps the handler, requiring a verified client certificate
+// when a client CA is set, and the basic auth credentials or the bearer token
+// when set. Either is accepted when both are set. Unauthorized requests are
+// challenged with the given realm.
+func (s serverSecurity) authenticate(realm string, h http.Handler) http.Handler {
+	if s.clientCAFile != "" {
+		h = requireClientCert(h)
+	}
+	if s.user == "" && s.token == "" {
+		return h
+	}
+
+	challenge := "Bearer realm=" + strconv.Quote(realm)
+	if s.user != "" {
+		challenge = "Basic realm=" + strconv.Quote(realm)
+	}
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if !s.authorized(req) {
+			rw.Header().Set("WWW-Authenticate", challenge)
+			http.Error(rw, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(rw, req)
+	})
+}
+
+// requireClientCert wraps the handler, requiring a verified client certificate.
+func requireClientCert(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.TLS == nil || len(req.TLS.VerifiedChains) == 0 {
+			http.Error(rw, "client certificate required", http.StatusForbidden)
+			return
+		}
+		h.ServeHTTP(rw, req)
+	})
+}
+
+func (s serverSecurity) authorized(req *http.Request) bool {
+	if s.user != "" {
+		if user, pass, ok := req.BasicAuth(); ok {
+			userOK := subtle.ConstantTimeCompare([]byte(user), []byte(s.user)) == 1
+			passOK := subtle.ConstantTimeCompare([]byte(pass), []byte(s.password)) == 1
+			if userOK && passOK {
+				return true
+			}
+		}
+	}
+	if s.token != "" {
+		if token, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer "); ok {
+			return subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) == 1
+		}
+	}
+	return false
+}
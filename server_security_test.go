@@ -0,0 +1,425 @@
+package cmd_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hamba/cmd/v3"
+	"github.com/hamba/logger/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v3"
+)
+
+func TestNewStatter_ServerAuth(t *testing.T) {
+	tests := []struct {
+		name     string
+		dsn      string
+		setAuth  func(req *http.Request)
+		wantCode int
+	}{
+		{
+			name:     "no credentials",
+			dsn:      "prom://user:pass@%s?scrapeWindow=0s",
+			setAuth:  func(*http.Request) {},
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "basic auth",
+			dsn:      "prom://user:pass@%s?scrapeWindow=0s",
+			setAuth:  func(req *http.Request) { req.SetBasicAuth("user", "pass") },
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "wrong basic auth",
+			dsn:      "vm://user:pass@%s?scrapeWindow=0s",
+			setAuth:  func(req *http.Request) { req.SetBasicAuth("user", "nope") },
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "bearer token",
+			dsn:      "vm://%s?token=secret&scrapeWindow=0s",
+			setAuth:  func(req *http.Request) { req.Header.Set("Authorization", "Bearer secret") },
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "wrong bearer token",
+			dsn:      "prom://%s?token=secret&scrapeWindow=0s",
+			setAuth:  func(req *http.Request) { req.Header.Set("Authorization", "Bearer nope") },
+			wantCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			log := logger.New(io.Discard, logger.LogfmtFormat(), logger.Error)
+			addr := freeAddr(t)
+			stats := newServerStatter(t, log, fmt.Sprintf(test.dsn, addr))
+			t.Cleanup(func() { _ = stats.Close() })
+
+			req, err := http.NewRequest(http.MethodGet, "http://"+addr+"/metrics", nil)
+			require.NoError(t, err)
+			test.setAuth(req)
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			_ = resp.Body.Close()
+
+			assert.Equal(t, test.wantCode, resp.StatusCode)
+			if test.wantCode == http.StatusUnauthorized {
+				assert.Contains(t, resp.Header.Get("WWW-Authenticate"), `realm="metrics"`)
+			}
+		})
+	}
+}
+
+func TestNewStatter_ServerTLS(t *testing.T) {
+	certs := newTestCerts(t)
+	log := logger.New(io.Discard, logger.LogfmtFormat(), logger.Error)
+	addr := freeAddr(t)
+
+	dsn := "prom://" + addr + "?tlsCert=" + certs.certFile + "&tlsKey=" + certs.keyFile + "&tlsClientCA=" + certs.caFile + "&scrapeWindow=0s"
+	stats := newServerStatter(t, log, dsn)
+	t.Cleanup(func() { _ = stats.Close() })
+
+	withCert := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		RootCAs:      certs.pool,
+		Certificates: []tls.Certificate{certs.client},
+	}}}
+	resp, err := withCert.Get("https://" + addr + "/metrics")
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	withoutCert := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: certs.pool}}}
+	resp, err = withoutCert.Get("https://" + addr + "/metrics")
+	if err == nil {
+		_ = resp.Body.Close()
+	}
+	assert.Error(t, err)
+}
+
+func TestNewStatter_ServerTLSRotation(t *testing.T) {
+	certs := newTestCerts(t)
+	log := logger.New(io.Discard, logger.LogfmtFormat(), logger.Error)
+	addr := freeAddr(t)
+
+	dsn := "prom://" + addr + "?tlsCert=" + certs.certFile + "&tlsKey=" + certs.keyFile + "&scrapeWindow=0s"
+	stats := newServerStatter(t, log, dsn)
+	t.Cleanup(func() { _ = stats.Close() })
+
+	fetch := func(pool *x509.CertPool) error {
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+		resp, err := client.Get("https://" + addr + "/metrics")
+		if err != nil {
+			return err
+		}
+		return resp.Body.Close()
+	}
+	require.NoError(t, fetch(certs.pool))
+
+	rotated := newTestCerts(t)
+	later := time.Now().Add(time.Minute)
+	for src, dst := range map[string]string{rotated.certFile: certs.certFile, rotated.keyFile: certs.keyFile} {
+		b, err := os.ReadFile(src)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(dst, b, 0o600))
+		require.NoError(t, os.Chtimes(dst, later, later))
+	}
+
+	assert.NoError(t, fetch(rotated.pool))
+	assert.Error(t, fetch(certs.pool))
+}
+
+func TestNewStatter_ServerSecurityErrors(t *testing.T) {
+	certs := newTestCerts(t)
+
+	tests := []struct {
+		name string
+		dsn  string
+	}{
+		{
+			name: "cert without key",
+			dsn:  "prom://:0?tlsCert=" + certs.certFile,
+		},
+		{
+			name: "client ca without cert",
+			dsn:  "vm://:0?tlsClientCA=" + certs.caFile,
+		},
+		{
+			name: "missing cert file",
+			dsn:  "prom://:0?tlsCert=/does/not/exist.pem&tlsKey=" + certs.keyFile,
+		},
+		{
+			name: "invalid client ca",
+			dsn:  "prom://:0?tlsCert=" + certs.certFile + "&tlsKey=" + certs.keyFile + "&tlsClientCA=" + certs.keyFile,
+		},
+		{
+			name: "unknown option",
+			dsn:  "prom://:0?tlsCertFile=" + certs.certFile,
+		},
+		{
+			name: "user info without host",
+			dsn:  "prom://user:pass@",
+		},
+		{
+			name: "token without host",
+			dsn:  "vm://?token=secret",
+		},
+		{
+			name: "user without password",
+			dsn:  "prom://user@:0",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := runStatter(t, "--stats.dsn="+test.dsn)
+
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestNewAdminServer_Security(t *testing.T) {
+	certs := newTestCerts(t)
+	log := logger.New(io.Discard, logger.LogfmtFormat(), logger.Error)
+
+	var admin *cmd.AdminServer
+	c := &cli.Command{
+		Flags: cmd.AdminFlags,
+		Action: func(_ context.Context, c *cli.Command) error {
+			var err error
+			admin, err = cmd.NewAdminServer(c, "my-service", log, nil)
+			return err
+		},
+	}
+	err := c.Run(t.Context(), []string{
+		"test",
+		"--admin.addr=127.0.0.1:0",
+		"--admin.tls-cert=" + certs.certFile,
+		"--admin.tls-key=" + certs.keyFile,
+		"--admin.basic-auth=user:pass",
+		"--admin.bearer-token=secret",
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = admin.Close() })
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: certs.pool}}}
+	base := "https://" + admin.Addr().String()
+
+	tests := []struct {
+		name     string
+		path     string
+		setAuth  func(req *http.Request)
+		wantCode int
+	}{
+		{
+			name:     "unauthenticated version",
+			path:     "/version",
+			setAuth:  func(*http.Request) {},
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "basic auth version",
+			path:     "/version",
+			setAuth:  func(req *http.Request) { req.SetBasicAuth("user", "pass") },
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "bearer token pprof",
+			path:     "/debug/pprof/cmdline",
+			setAuth:  func(req *http.Request) { req.Header.Set("Authorization", "Bearer secret") },
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "unauthenticated health",
+			path:     "/healthz",
+			setAuth:  func(*http.Request) {},
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "unauthenticated readiness",
+			path:     "/readyz",
+			setAuth:  func(*http.Request) {},
+			wantCode: http.StatusOK,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, base+test.path, nil)
+			require.NoError(t, err)
+			test.setAuth(req)
+
+			resp, err := client.Do(req)
+			require.NoError(t, err)
+			_ = resp.Body.Close()
+
+			assert.Equal(t, test.wantCode, resp.StatusCode)
+			if test.wantCode == http.StatusUnauthorized {
+				assert.Equal(t, `Basic realm="admin"`, resp.Header.Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
+
+func TestNewAdminServer_ClientCert(t *testing.T) {
+	certs := newTestCerts(t)
+	log := logger.New(io.Discard, logger.LogfmtFormat(), logger.Error)
+
+	var admin *cmd.AdminServer
+	c := &cli.Command{
+		Flags: cmd.AdminFlags,
+		Action: func(_ context.Context, c *cli.Command) error {
+			var err error
+			admin, err = cmd.NewAdminServer(c, "my-service", log, nil)
+			return err
+		},
+	}
+	err := c.Run(t.Context(), []string{
+		"test",
+		"--admin.addr=127.0.0.1:0",
+		"--admin.tls-cert=" + certs.certFile,
+		"--admin.tls-key=" + certs.keyFile,
+		"--admin.tls-client-ca=" + certs.caFile,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = admin.Close() })
+
+	base := "https://" + admin.Addr().String()
+	withCert := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		RootCAs:      certs.pool,
+		Certificates: []tls.Certificate{certs.client},
+	}}}
+	withoutCert := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: certs.pool}}}
+
+	tests := []struct {
+		name     string
+		client   *http.Client
+		path     string
+		wantCode int
+	}{
+		{
+			name:     "probe without cert",
+			client:   withoutCert,
+			path:     "/healthz",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "readiness without cert",
+			client:   withoutCert,
+			path:     "/readyz",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "version without cert",
+			client:   withoutCert,
+			path:     "/version",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "version with cert",
+			client:   withCert,
+			path:     "/version",
+			wantCode: http.StatusOK,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp, err := test.client.Get(base + test.path)
+			require.NoError(t, err)
+			_ = resp.Body.Close()
+
+			assert.Equal(t, test.wantCode, resp.StatusCode)
+		})
+	}
+}
+
+type testCerts struct {
+	caFile   string
+	certFile string
+	keyFile  string
+	pool     *x509.CertPool
+	client   tls.Certificate
+}
+
+// newTestCerts writes a CA and a server certificate for 127.0.0.1 signed by it,
+// returning the files along with a client certificate signed by the CA.
+func newTestCerts(t *testing.T) testCerts {
+	t.Helper()
+
+	dir := t.TempDir()
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	require.NoError(t, err)
+	ca, err := x509.ParseCertificate(caDER)
+	require.NoError(t, err)
+
+	issue := func(serial int64, usage x509.ExtKeyUsage) ([]byte, *ecdsa.PrivateKey) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		tmpl := &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			Subject:      pkix.Name{CommonName: "test"},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+			IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey)
+		require.NoError(t, err)
+		return der, key
+	}
+
+	writePEM := func(name, typ string, b []byte) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: b}), 0o600))
+		return path
+	}
+
+	srvDER, srvKey := issue(2, x509.ExtKeyUsageServerAuth)
+	srvKeyDER, err := x509.MarshalECPrivateKey(srvKey)
+	require.NoError(t, err)
+
+	cliDER, cliKey := issue(3, x509.ExtKeyUsageClientAuth)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+
+	return testCerts{
+		caFile:   writePEM("ca.pem", "CERTIFICATE", caDER),
+		certFile: writePEM("cert.pem", "CERTIFICATE", srvDER),
+		keyFile:  writePEM("key.pem", "EC PRIVATE KEY", srvKeyDER),
+		pool:     pool,
+		client:   tls.Certificate{Certificate: [][]byte{cliDER}, PrivateKey: cliKey},
+	}
+}
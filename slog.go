@@ -0,0 +1,165 @@
+package cmd
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/hamba/logger/v2"
+	"github.com/hamba/logger/v2/ctx"
+	"github.com/urfave/cli/v3"
+)
+
+// NewSlogLogger returns a slog logger configured from the cli.
+func NewSlogLogger(cmd *cli.Command) (*slog.Logger, error) {
+	return NewSlogLoggerWithOptions(cmd, &LoggerOptions{})
+}
+
+// NewSlogLoggerWithOptions returns a slog logger configured from the cli.
+func NewSlogLoggerWithOptions(cmd *cli.Command, opts *LoggerOptions) (*slog.Logger, error) {
+	log, lvl, err := NewLoggerWithLevel(cmd, opts)
+	if err != nil {
+		return nil, err
+	}
+	return slog.New(NewSlogHandler(log, lvl)), nil
+}
+
+// SlogHandler is a slog handler that writes records to a logger.
+type SlogHandler struct {
+	log    *logger.Logger
+	lvl    *LogLevel
+	prefix string
+}
+
+// NewSlogHandler returns a slog handler that writes records to the given logger.
+// The level is used to skip records that would be filtered by the logger.
+// If the level is nil, all records are passed to the logger.
+func NewSlogHandler(log *logger.Logger, lvl *LogLevel) *SlogHandler {
+	return &SlogHandler{
+		log: log,
+		lvl: lvl,
+	}
+}
+
+// Enabled reports whether the handler handles records at the given level.
+func (h *SlogHandler) Enabled(_ context.Context, lvl slog.Level) bool {
+	if h.lvl == nil {
+		return true
+	}
+	return h.lvl.Enabled(levelFromSlog(lvl))
+}
+
+// Handle handles the record, adding the trace and
+// span IDs of the span in the context.
+func (h *SlogHandler) Handle(c context.Context, rec slog.Record) error {
+	fields := make([]logger.Field, 0, rec.NumAttrs()+2)
+	fields = append(fields, TraceFields(c)...)
+	rec.Attrs(func(attr slog.Attr) bool {
+		fields = appendSlogAttr(fields, h.prefix, attr)
+		return true
+	})
+
+	log := h.log.FromContext(c)
+	switch lvl := levelFromSlog(rec.Level); lvl {
+	case logger.Trace:
+		log.Trace(rec.Message, fields...)
+	case logger.Debug:
+		log.Debug(rec.Message, fields...)
+	case logger.Info:
+		log.Info(rec.Message, fields...)
+	case logger.Warn:
+		log.Warn(rec.Message, fields...)
+	case logger.Error:
+		log.Error(rec.Message, fields...)
+	default:
+		log.Crit(rec.Message, fields...)
+	}
+	return nil
+}
+
+// WithAttrs returns a handler with the given attributes added to every record.
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+
+	fields := make([]logger.Field, 0, len(attrs))
+	for _, attr := range attrs {
+		fields = appendSlogAttr(fields, h.prefix, attr)
+	}
+
+	return &SlogHandler{
+		log:    h.log.With(fields...),
+		lvl:    h.lvl,
+		prefix: h.prefix,
+	}
+}
+
+// WithGroup returns a handler that qualifies the keys of subsequent attributes with the group name.
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+
+	return &SlogHandler{
+		log:    h.log,
+		lvl:    h.lvl,
+		prefix: h.prefix + name + ".",
+	}
+}
+
+func levelFromSlog(lvl slog.Level) logger.Level {
+	switch {
+	case lvl < slog.LevelDebug:
+		return logger.Trace
+	case lvl < slog.LevelInfo:
+		return logger.Debug
+	case lvl < slog.LevelWarn:
+		return logger.Info
+	case lvl < slog.LevelError:
+		return logger.Warn
+	case lvl == slog.LevelError:
+		return logger.Error
+	default:
+		return logger.Crit
+	}
+}
+
+func appendSlogAttr(fields []logger.Field, prefix string, attr slog.Attr) []logger.Field {
+	attr.Value = attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		return fields
+	}
+
+	val := attr.Value
+	key := prefix + attr.Key
+	switch val.Kind() {
+	case slog.KindGroup:
+		grpPrefix := prefix
+		if attr.Key != "" {
+			grpPrefix = key + "."
+		}
+		for _, a := range val.Group() {
+			fields = appendSlogAttr(fields, grpPrefix, a)
+		}
+		return fields
+	case slog.KindString:
+		return append(fields, ctx.Str(key, val.String()))
+	case slog.KindInt64:
+		return append(fields, ctx.Int64(key, val.Int64()))
+	case slog.KindUint64:
+		return append(fields, ctx.Uint64(key, val.Uint64()))
+	case slog.KindFloat64:
+		return append(fields, ctx.Float64(key, val.Float64()))
+	case slog.KindBool:
+		return append(fields, ctx.Bool(key, val.Bool()))
+	case slog.KindDuration:
+		return append(fields, ctx.Duration(key, val.Duration()))
+	case slog.KindTime:
+		return append(fields, ctx.Time(key, val.Time()))
+	default:
+		if err, ok := val.Any().(error); ok {
+			return append(fields, ctx.Error(key, err))
+		}
+		return append(fields, ctx.Interface(key, val.Any()))
+	}
+}
@@ -0,0 +1,121 @@
+package cmd_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/hamba/cmd/v3"
+	"github.com/hamba/logger/v2"
+	"github.com/hamba/logger/v2/ctx"
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli/v3"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestNewSlogLogger(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{
+			name:    "valid",
+			args:    []string{"--log.level=info", "--log.format=json", "--log.ctx=a=b"},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "invalid level",
+			args:    []string{"--log.level=invalid"},
+			wantErr: assert.Error,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := &cli.Command{
+				Flags: cmd.LogFlags,
+				Action: func(_ context.Context, c *cli.Command) error {
+					_, err := cmd.NewSlogLogger(c)
+					return err
+				},
+			}
+
+			err := c.Run(t.Context(), append([]string{"test"}, test.args...))
+
+			test.wantErr(t, err)
+		})
+	}
+}
+
+func TestNewSlogLoggerWithOptions(t *testing.T) {
+	var buf bytes.Buffer
+	log := newTestLogger(t, cmd.NewSlogLoggerWithOptions, &buf, "--log.level=info", "--log.format=logfmt", "--log.ctx=a=b")
+
+	log.Debug("dropped")
+	log.Info("info", "str", "string", "int", 1, "float", 1.5, "bool", true, "dur", time.Second)
+	log.Warn("warn", slog.Group("grp", "k", "v"), "err", errors.New("test error"))
+	log.Error("error")
+	log.Log(t.Context(), slog.LevelError+4, "crit")
+	log.With("w", "v").WithGroup("g").Info("group", "k", "v")
+
+	want := "lvl=info msg=info a=b str=string int=1 float=1.500 bool=true dur=1s\n" +
+		"lvl=warn msg=warn a=b grp.k=v err=\"test error\"\n" +
+		"lvl=eror msg=error a=b\n" +
+		"lvl=crit msg=crit a=b\n" +
+		"lvl=info msg=group a=b w=v g.k=v\n"
+	assert.Equal(t, want, buf.String())
+}
+
+func TestNewSlogLoggerWithOptions_ComponentLevels(t *testing.T) {
+	var buf bytes.Buffer
+	log := newTestLogger(t, cmd.NewSlogLoggerWithOptions, &buf, "--log.level=info,db=debug", "--log.format=logfmt")
+
+	log.Debug("dropped")
+	log.With("component", "db").Debug("kept")
+
+	assert.Equal(t, "lvl=dbug msg=kept component=db\n", buf.String())
+}
+
+func TestSlogHandler_ContextFields(t *testing.T) {
+	var buf bytes.Buffer
+	log := logger.New(&buf, logger.LogfmtFormat(), logger.Info)
+	slogger := slog.New(cmd.NewSlogHandler(log, nil))
+
+	c := logger.WithContext(t.Context(), log, ctx.Str("req", "123"))
+	slogger.InfoContext(c, "test")
+
+	assert.Equal(t, "lvl=info msg=test req=123\n", buf.String())
+}
+
+func TestSlogHandler_TraceFields(t *testing.T) {
+	var buf bytes.Buffer
+	log := logger.New(&buf, logger.LogfmtFormat(), logger.Info)
+	slogger := slog.New(cmd.NewSlogHandler(log, nil))
+
+	c := trace.ContextWithSpanContext(t.Context(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{1},
+		SpanID:  trace.SpanID{2},
+	}))
+	slogger.InfoContext(c, "test", "a", "b")
+
+	want := "lvl=info msg=test trace_id=01000000000000000000000000000000 span_id=0200000000000000 a=b\n"
+	assert.Equal(t, want, buf.String())
+}
+
+func TestSlogHandler_Enabled(t *testing.T) {
+	var buf bytes.Buffer
+	log := newTestLogger(t, newLevelLogger, &buf, "--log.level=warn")
+	h := cmd.NewSlogHandler(log.Logger, log.lvl)
+
+	assert.False(t, h.Enabled(t.Context(), slog.LevelInfo))
+	assert.True(t, h.Enabled(t.Context(), slog.LevelWarn))
+
+	log.lvl.SetLevel(logger.Debug)
+
+	assert.True(t, h.Enabled(t.Context(), slog.LevelDebug))
+	assert.True(t, cmd.NewSlogHandler(log.Logger, nil).Enabled(t.Context(), slog.LevelDebug-4))
+}
@@ -3,20 +3,22 @@ package cmd
 import (
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
-	"strconv"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/ettle/strcase"
 	"github.com/hamba/logger/v2"
-	"github.com/hamba/logger/v2/ctx"
 	"github.com/hamba/statter/v2"
 	"github.com/hamba/statter/v2/reporter/l2met"
 	"github.com/hamba/statter/v2/reporter/prometheus"
-	"github.com/hamba/statter/v2/reporter/statsd"
 	"github.com/hamba/statter/v2/reporter/victoriametrics"
+	"github.com/hamba/statter/v2/runtime"
 	"github.com/urfave/cli/v3"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 const defaultStatsInterval = time.Second
@@ -27,6 +29,9 @@ const (
 	FlagStatsInterval = "stats.interval"
 	FlagStatsPrefix   = "stats.prefix"
 	FlagStatsTags     = "stats.tags"
+
+	FlagStatsRuntime         = "stats.runtime"
+	FlagStatsRuntimeInterval = "stats.runtime-interval"
 )
 
 // CategoryStats is the stats flag category.
@@ -34,10 +39,10 @@ const CategoryStats = "Stats"
 
 // StatsFlags are flags that configure stats.
 var StatsFlags = Flags{
-	&cli.StringFlag{
+	&cli.StringSliceFlag{
 		Name:     FlagStatsDSN,
 		Category: CategoryStats,
-		Usage:    "The DSN of a stats backend.",
+		Usage:    "The DSN of a stats backend. Can be repeated to report to several backends.",
 		Sources:  cli.EnvVars(strcase.ToSNAKE(FlagStatsDSN)),
 	},
 	&cli.DurationFlag{
@@ -59,24 +64,71 @@ var StatsFlags = Flags{
 		Usage:    "A list of tags appended to every measurement.",
 		Sources:  cli.EnvVars(strcase.ToSNAKE(FlagStatsTags)),
 	},
+	&cli.BoolFlag{
+		Name:     FlagStatsRuntime,
+		Category: CategoryStats,
+		Usage:    "Determines if Go runtime stats are collected.",
+		Sources:  cli.EnvVars(strcase.ToSNAKE(FlagStatsRuntime)),
+	},
+	&cli.DurationFlag{
+		Name:     FlagStatsRuntimeInterval,
+		Category: CategoryStats,
+		Usage:    "The frequency at which the Go runtime stats are collected.",
+		Value:    runtime.DefaultRuntimeInterval,
+		Sources:  cli.EnvVars(strcase.ToSNAKE(FlagStatsRuntimeInterval)),
+	},
 }
 
 // NewStatter returns a statter configured from the cli.
 func NewStatter(cmd *cli.Command, log *logger.Logger, opts ...statter.Option) (*statter.Statter, error) {
-	r, err := createReporter(cmd, log)
-	if err != nil {
-		return nil, err
-	}
+	stats, _, err := newStatter(cmd, log, nil, opts)
+	return stats, err
+}
 
+// NewStatterWithHandler returns a statter configured from the cli, along with
+// the handler serving its metrics. The handler is only returned for a prometheus
+// or victoria metrics DSN without a host, that does not start its own server.
+// If there is no such DSN, the handler is nil.
+//
+// The attributes, such as the service name, are set on the resource of OTLP metrics.
+func NewStatterWithHandler(cmd *cli.Command, log *logger.Logger, attrs ...attribute.KeyValue) (*statter.Statter, http.Handler, error) {
+	return newStatter(cmd, log, attrs, nil)
+}
+
+func newStatter(cmd *cli.Command, log *logger.Logger, attrs []attribute.KeyValue, opts []statter.Option) (*statter.Statter, http.Handler, error) {
 	intv := cmd.Duration(FlagStatsInterval)
 	if intv <= 0 {
 		intv = defaultStatsInterval
 	}
+
+	r, err := createReporter(cmd, log, intv, attrs, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	prefix, tags := statsWith(cmd)
 
 	opts = append(opts, statter.WithPrefix(prefix), statter.WithTags(tags...))
 
-	return statter.New(r, intv, opts...), nil
+	return statter.New(r, intv, opts...), metricsHandler(r), nil
+}
+
+// metricsHandler returns the handler of the first reporter that
+// serves its metrics over HTTP without a server of its own.
+func metricsHandler(r statter.Reporter) http.Handler {
+	switch r := r.(type) {
+	case *prometheus.Prometheus:
+		return r.Handler()
+	case *victoriametrics.VictoriaMetrics:
+		return r.Handler()
+	case *fanoutReporter:
+		for _, rr := range r.rs {
+			if h := metricsHandler(rr); h != nil {
+				return h
+			}
+		}
+	}
+	return nil
 }
 
 func statsWith(cmd *cli.Command) (string, []statter.Tag) {
@@ -90,93 +142,148 @@ func statsWith(cmd *cli.Command) (string, []statter.Tag) {
 	return cmd.String(FlagStatsPrefix), tags
 }
 
-func createReporter(cmd *cli.Command, log *logger.Logger) (statter.Reporter, error) {
-	dsn := cmd.String(FlagStatsDSN)
-	if dsn == "" {
+// createReporter returns the reporter of each stats DSN, reporting
+// to all of them when several are given.
+func createReporter(cmd *cli.Command, log *logger.Logger, intv time.Duration, attrs []attribute.KeyValue, opts []statter.Option) (statter.Reporter, error) {
+	tags := cmd.StringMap(FlagStatsTags)
+
+	var rs []statter.Reporter
+	for _, dsn := range statsDSNs(cmd) {
+		r, err := newReporter(dsn, intv, tags, attrs, log)
+		if err != nil {
+			for _, r := range rs {
+				if c, ok := r.(io.Closer); ok {
+					_ = c.Close()
+				}
+			}
+			return nil, err
+		}
+		rs = append(rs, r)
+	}
+
+	switch len(rs) {
+	case 0:
 		return statter.DiscardReporter, nil
+	case 1:
+		return rs[0], nil
+	default:
+		return newFanoutReporter(rs, intv, opts), nil
 	}
+}
 
-	uri, err := url.Parse(dsn)
+// statsDSNs returns the configured stats DSNs. The flag values are split on
+// commas, which may also be part of the DSN options, so a value that does not
+// start with a scheme is joined back onto the DSN before it.
+func statsDSNs(cmd *cli.Command) []string {
+	var dsns []string
+	for _, s := range cmd.StringSlice(FlagStatsDSN) {
+		switch {
+		case len(dsns) > 0 && !hasScheme(s):
+			dsns[len(dsns)-1] += "," + s
+		case s != "":
+			dsns = append(dsns, s)
+		}
+	}
+	return dsns
+}
+
+// hasScheme reports whether s starts with a URL scheme followed by "://".
+func hasScheme(s string) bool {
+	scheme, _, ok := strings.Cut(s, "://")
+	if !ok || scheme == "" {
+		return false
+	}
+	for i, r := range scheme {
+		switch {
+		case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z':
+		case i > 0 && ('0' <= r && r <= '9' || r == '+' || r == '-' || r == '.'):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func newReporter(dsn string, intv time.Duration, tags map[string]string, attrs []attribute.KeyValue, log *logger.Logger) (statter.Reporter, error) {
+	uri, err := parseStatsDSN(dsn)
 	if err != nil {
 		return nil, err
 	}
 
 	switch uri.Scheme {
-	case "statsd":
-		return newStatsd(uri.Host, uri.Query())
+	case "statsd", "statsd+tcp", "statsd+unix":
+		return newStatsdStats(uri, log)
 	case "l2met":
 		return l2met.New(log, ""), nil
 	case "prometheus", "prom":
-		return newPrometheusStats(uri.Host, log), nil
+		return newPrometheusStats(uri, log)
 	case "victoriametrics", "vm":
-		return newVictoriaMetricsStats(uri.Host, log), nil
+		return newVictoriaMetricsStats(uri, log)
+	case "otlphttp", "otlpgrpc":
+		return newOTLPStats(uri, intv, attrs)
+	case "pushgateway", "pushgateway+https":
+		return newPushgatewayStats(uri, tags, intv, log)
+	case "graphite":
+		return newGraphiteStats(uri, log)
+	case "influx+udp", "influx+http", "influx+https":
+		return newInfluxStats(uri, log)
 	default:
 		return nil, fmt.Errorf("unsupported stats reporter: %s", uri.Scheme)
 	}
 }
 
-func newStatsd(addr string, qry url.Values) (*statsd.Statsd, error) {
-	var opts []statsd.Option
-	if s := qry.Get("flushBytes"); s != "" {
-		n, err := strconv.Atoi(s)
-		if err == nil {
-			opts = append(opts, statsd.WithFlushBytes(n))
+// statsSchemes are the supported stats DSN schemes.
+var statsSchemes = []string{
+	"statsd", "statsd+tcp", "statsd+unix", "l2met", "prometheus", "prom", "victoriametrics", "vm", "otlphttp", "otlpgrpc",
+	"pushgateway", "pushgateway+https", "graphite", "influx+udp", "influx+http", "influx+https",
+}
+
+func parseStatsDSN(dsn string) (*url.URL, error) {
+	uri, err := url.Parse(dsn)
+	if err != nil {
+		// The parse error contains the DSN, along with any credentials in it.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return nil, urlErr.Err
 		}
+		return nil, err
 	}
-	if s := qry.Get("flushInterval"); s != "" {
-		d, err := time.ParseDuration(s)
-		if err == nil {
-			opts = append(opts, statsd.WithFlushInterval(d))
-		}
+	if !slices.Contains(statsSchemes, uri.Scheme) {
+		return nil, fmt.Errorf("unsupported stats reporter: %s", uri.Scheme)
 	}
-
-	return statsd.New(addr, "", opts...)
+	return uri, nil
 }
 
-func newPrometheusStats(addr string, log *logger.Logger) *prometheus.Prometheus {
-	r := prometheus.New("")
-
-	if addr != "" {
-		mux := http.NewServeMux()
-		mux.Handle("/metrics", r.Handler())
-		go func() {
-			srv := http.Server{
-				Addr:              addr,
-				Handler:           mux,
-				ReadHeaderTimeout: time.Second,
-				ReadTimeout:       10 * time.Second,
-				WriteTimeout:      10 * time.Second,
-				IdleTimeout:       120 * time.Second,
-			}
-			if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-				log.Error(err.Error(), ctx.Str("server", "prometheus"))
-			}
-		}()
+func newPrometheusStats(uri *url.URL, log *logger.Logger) (statter.Reporter, error) {
+	srvOpts, err := parseMetricsServerOptions(uri)
+	if err != nil {
+		return nil, err
 	}
 
-	return r
+	r := prometheus.New("")
+	if uri.Host == "" {
+		return r, nil
+	}
+	srv, err := startMetricsServer(uri.Host, r.Handler(), srvOpts, "prometheus", log)
+	if err != nil {
+		return nil, err
+	}
+	return prometheusStats{Prometheus: r, srv: srv}, nil
 }
 
-func newVictoriaMetricsStats(addr string, log *logger.Logger) *victoriametrics.VictoriaMetrics {
-	r := victoriametrics.New()
-
-	if addr != "" {
-		mux := http.NewServeMux()
-		mux.Handle("/metrics", r.Handler())
-		go func() {
-			srv := http.Server{
-				Addr:              addr,
-				Handler:           mux,
-				ReadHeaderTimeout: time.Second,
-				ReadTimeout:       10 * time.Second,
-				WriteTimeout:      10 * time.Second,
-				IdleTimeout:       120 * time.Second,
-			}
-			if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-				log.Error(err.Error(), ctx.Str("server", "victoria-metrics"))
-			}
-		}()
+func newVictoriaMetricsStats(uri *url.URL, log *logger.Logger) (statter.Reporter, error) {
+	srvOpts, err := parseMetricsServerOptions(uri)
+	if err != nil {
+		return nil, err
 	}
 
-	return r
+	r := victoriametrics.New()
+	if uri.Host == "" {
+		return r, nil
+	}
+	srv, err := startMetricsServer(uri.Host, r.Handler(), srvOpts, "victoria-metrics", log)
+	if err != nil {
+		return nil, err
+	}
+	return victoriaMetricsStats{VictoriaMetrics: r, srv: srv}, nil
 }
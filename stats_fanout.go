@@ -0,0 +1,162 @@
+package cmd
+
+import (
+	"errors"
+	"io"
+	"time"
+
+	"github.com/hamba/statter/v2"
+)
+
+// fanoutReporter reports stats to several reporters.
+//
+// Histograms and timings are delegated to the reporters that handle them.
+// For the other reporters, they are aggregated by a statter of their own.
+type fanoutReporter struct {
+	rs []statter.Reporter
+
+	// histAggs and timingAggs are the statters aggregating histograms and
+	// timings for the reporters not handling them, by reporter index in aggs.
+	histAggs   []*statter.Statter
+	timingAggs []*statter.Statter
+	aggs       map[int]*statter.Statter
+}
+
+// newFanoutReporter returns a reporter reporting to all the given reporters.
+// Aggregated histograms and timings are reported at the given interval.
+func newFanoutReporter(rs []statter.Reporter, intv time.Duration, opts []statter.Option) *fanoutReporter {
+	opts = append(opts[:len(opts):len(opts)], statter.WithPrefix(""), statter.WithTags())
+
+	f := &fanoutReporter{rs: rs, aggs: map[int]*statter.Statter{}}
+	for i, r := range rs {
+		_, isHist := r.(statter.HistogramReporter)
+		_, isTiming := r.(statter.TimingReporter)
+		if isHist && isTiming {
+			continue
+		}
+
+		agg := statter.New(r, intv, opts...)
+		f.aggs[i] = agg
+		if !isHist {
+			f.histAggs = append(f.histAggs, agg)
+		}
+		if !isTiming {
+			f.timingAggs = append(f.timingAggs, agg)
+		}
+	}
+	return f
+}
+
+// Counter reports a counter value.
+func (f *fanoutReporter) Counter(name string, v int64, tags [][2]string) {
+	for _, r := range f.rs {
+		r.Counter(name, v, tags)
+	}
+}
+
+// Gauge reports a gauge value.
+func (f *fanoutReporter) Gauge(name string, v float64, tags [][2]string) {
+	for _, r := range f.rs {
+		r.Gauge(name, v, tags)
+	}
+}
+
+// Histogram returns a histogram reporting to all reporters.
+func (f *fanoutReporter) Histogram(name string, tags [][2]string) func(v float64) {
+	var fns []func(v float64)
+	for _, r := range f.rs {
+		if hr, ok := r.(statter.HistogramReporter); ok {
+			if fn := hr.Histogram(name, tags); fn != nil {
+				fns = append(fns, fn)
+			}
+		}
+	}
+	for _, agg := range f.histAggs {
+		fns = append(fns, agg.Histogram(name, tags...).Observe)
+	}
+
+	return func(v float64) {
+		for _, fn := range fns {
+			fn(v)
+		}
+	}
+}
+
+// Timing returns a timing reporting to all reporters.
+func (f *fanoutReporter) Timing(name string, tags [][2]string) func(v time.Duration) {
+	var fns []func(v time.Duration)
+	for _, r := range f.rs {
+		if tr, ok := r.(statter.TimingReporter); ok {
+			if fn := tr.Timing(name, tags); fn != nil {
+				fns = append(fns, fn)
+			}
+		}
+	}
+	for _, agg := range f.timingAggs {
+		fns = append(fns, agg.Timing(name, tags...).Observe)
+	}
+
+	return func(v time.Duration) {
+		for _, fn := range fns {
+			fn(v)
+		}
+	}
+}
+
+// RemoveCounter removes the counter from the reporters handling removal.
+func (f *fanoutReporter) RemoveCounter(name string, tags [][2]string) {
+	for _, r := range f.rs {
+		if rr, ok := r.(statter.RemovableReporter); ok {
+			rr.RemoveCounter(name, tags)
+		}
+	}
+}
+
+// RemoveGauge removes the gauge from the reporters handling removal.
+func (f *fanoutReporter) RemoveGauge(name string, tags [][2]string) {
+	for _, r := range f.rs {
+		if rr, ok := r.(statter.RemovableReporter); ok {
+			rr.RemoveGauge(name, tags)
+		}
+	}
+}
+
+// RemoveHistogram removes the histogram from all reporters.
+func (f *fanoutReporter) RemoveHistogram(name string, tags [][2]string) {
+	for _, r := range f.rs {
+		if rr, ok := r.(statter.RemovableHistogramReporter); ok {
+			rr.RemoveHistogram(name, tags)
+		}
+	}
+	for _, agg := range f.histAggs {
+		agg.Histogram(name, tags...).Delete()
+	}
+}
+
+// RemoveTiming removes the timing from all reporters.
+func (f *fanoutReporter) RemoveTiming(name string, tags [][2]string) {
+	for _, r := range f.rs {
+		if rr, ok := r.(statter.RemovableTimingReporter); ok {
+			rr.RemoveTiming(name, tags)
+		}
+	}
+	for _, agg := range f.timingAggs {
+		agg.Timing(name, tags...).Delete()
+	}
+}
+
+// Close flushes the aggregated stats and closes the reporters.
+func (f *fanoutReporter) Close() error {
+	var errs []error
+	for i, r := range f.rs {
+		if agg, ok := f.aggs[i]; ok {
+			// Closing the statter also closes its reporter.
+			errs = append(errs, agg.Close())
+			continue
+		}
+		if c, ok := r.(io.Closer); ok {
+			errs = append(errs, c.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"math"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hamba/logger/v2"
+)
+
+const defaultGraphitePort = "2003"
+
+// graphiteEscaper replaces the characters that cannot be
+// used in graphite metric paths.
+var graphiteEscaper = strings.NewReplacer(" ", "_", ";", "_", "=", "_", "\n", "_", "\r", "_")
+
+// escapeGraphiteTag replaces the characters that cannot be used in graphite
+// tags. Besides the path separators, dots and anything outside of printable
+// ASCII are replaced, as they would be taken as part of the path or break the line.
+func escapeGraphiteTag(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r <= ' ' || r > '~', r == ';', r == '=', r == '.':
+			return '_'
+		default:
+			return r
+		}
+	}, s)
+}
+
+// newGraphiteStats returns a graphite reporter for the DSN, in the form
+// `graphite://host:port`, sending the plaintext protocol over tcp.
+func newGraphiteStats(uri *url.URL, log *logger.Logger) (*lineStats, error) {
+	opts, err := parseLineOptions(uri.Query())
+	if err != nil {
+		return nil, err
+	}
+
+	addr := uri.Host
+	if uri.Port() == "" {
+		addr = net.JoinHostPort(uri.Hostname(), defaultGraphitePort)
+	}
+	conn := &redialConn{network: "tcp", addr: addr}
+
+	return newLineStats("graphite", graphiteFormat{}, streamSender(conn), conn, opts, log), nil
+}
+
+// graphiteFormat formats stats in the graphite plaintext protocol. The stats
+// are named by their dotted path, with their tags as a tagged series.
+type graphiteFormat struct{}
+
+func (f graphiteFormat) appendCounter(b []byte, name string, v int64, tags [][2]string, ts time.Time) []byte {
+	b = f.appendPath(b, name, tags)
+	b = append(b, ' ')
+	b = strconv.AppendInt(b, v, 10)
+	return f.appendTime(b, ts)
+}
+
+func (f graphiteFormat) appendGauge(b []byte, name string, v float64, tags [][2]string, ts time.Time) []byte {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return b
+	}
+
+	b = f.appendPath(b, name, tags)
+	b = append(b, ' ')
+	b = strconv.AppendFloat(b, v, 'f', -1, 64)
+	return f.appendTime(b, ts)
+}
+
+func (graphiteFormat) appendPath(b []byte, name string, tags [][2]string) []byte {
+	b = append(b, graphiteEscaper.Replace(name)...)
+	for _, tag := range tags {
+		b = append(b, ';')
+		b = append(b, escapeGraphiteTag(tag[0])...)
+		b = append(b, '=')
+		b = append(b, escapeGraphiteTag(tag[1])...)
+	}
+	return b
+}
+
+func (graphiteFormat) appendTime(b []byte, ts time.Time) []byte {
+	b = append(b, ' ')
+	b = strconv.AppendInt(b, ts.Unix(), 10)
+	return append(b, '\n')
+}
@@ -0,0 +1,96 @@
+package cmd_test
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/hamba/logger/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewStatter_Graphite(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = ln.Close() })
+
+	lines := make(chan string, 10)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+
+		sc := bufio.NewScanner(conn)
+		for sc.Scan() {
+			lines <- sc.Text()
+		}
+	}()
+
+	log := logger.New(io.Discard, logger.LogfmtFormat(), logger.Error)
+	stats := newServerStatter(t, log, "graphite://"+ln.Addr().String(), "--stats.prefix=app", "--stats.tags=env=prod test,host=a.b\ncé", "--stats.interval=1h")
+
+	stats.Counter("requests").Inc(2)
+	stats.Gauge("queue size").Set(1.5)
+	require.NoError(t, stats.Close())
+
+	got := readLines(t, lines, 2)
+	assert.ElementsMatch(t, []string{"app.requests;env=prod_test;host=a_b_c_ 2", "app.queue_size;env=prod_test;host=a_b_c_ 1.5"}, trimTimestamps(t, got, `\d+`))
+}
+
+func TestNewStatter_GraphiteErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		dsn  string
+	}{
+		{
+			name: "invalid flush interval",
+			dsn:  "graphite://localhost:2003?flushInterval=soon",
+		},
+		{
+			name: "invalid flush bytes",
+			dsn:  "graphite://localhost:2003?flushBytes=-1",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := runStatter(t, "--stats.dsn="+test.dsn)
+
+			assert.Error(t, err)
+		})
+	}
+}
+
+func readLines(t *testing.T, lines <-chan string, n int) []string {
+	t.Helper()
+
+	var got []string
+	for range n {
+		select {
+		case line := <-lines:
+			got = append(got, line)
+		case <-time.After(time.Second):
+			require.FailNow(t, "timed out waiting for lines", "got %v", got)
+		}
+	}
+	return got
+}
+
+// trimTimestamps removes the trailing timestamp matching the pattern from the lines.
+func trimTimestamps(t *testing.T, lines []string, pattern string) []string {
+	t.Helper()
+
+	re := regexp.MustCompile(` ` + pattern + `$`)
+	trimmed := make([]string, len(lines))
+	for i, line := range lines {
+		require.Regexp(t, re, line)
+		trimmed[i] = re.ReplaceAllString(line, "")
+	}
+	return trimmed
+}
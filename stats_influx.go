@@ -0,0 +1,167 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hamba/logger/v2"
+)
+
+// influxWriteTimeout is the timeout of a write to the influx http api.
+const influxWriteTimeout = 10 * time.Second
+
+// Influx line protocol escapers.
+var (
+	influxMeasurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `, "\n", `\n`)
+	influxTagEscaper         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `, "\n", `\n`)
+)
+
+// newInfluxStats returns an influx reporter for the DSN, either
+// `influx+udp://host:port` or `influx+http(s)://host:port?db=name`.
+// With the http api, `bucket`, `org` and `token` write to the v2 api.
+func newInfluxStats(uri *url.URL, log *logger.Logger) (*lineStats, error) {
+	if uri.Host == "" {
+		return nil, errors.New("influx DSN requires a host")
+	}
+
+	opts, err := parseLineOptions(uri.Query())
+	if err != nil {
+		return nil, err
+	}
+
+	if uri.Scheme == "influx+udp" {
+		conn := &redialConn{network: "udp", addr: uri.Host}
+		return newLineStats("influx", influxFormat{}, packetSender(conn, maxLinePacketSize), conn, opts, log), nil
+	}
+
+	send, err := influxHTTPSender(uri)
+	if err != nil {
+		return nil, err
+	}
+	return newLineStats("influx", influxFormat{}, send, nil, opts, log), nil
+}
+
+// influxHTTPSender returns a sender writing lines to the influx http api.
+func influxHTTPSender(uri *url.URL) (func([]byte) error, error) {
+	qry := uri.Query()
+	scheme := strings.TrimPrefix(uri.Scheme, "influx+")
+
+	var (
+		u    *url.URL
+		auth string
+	)
+	switch {
+	case qry.Get("bucket") != "":
+		if qry.Get("org") == "" {
+			return nil, errors.New("influx DSN requires an org with a bucket")
+		}
+		params := url.Values{"org": {qry.Get("org")}, "bucket": {qry.Get("bucket")}, "precision": {"ns"}}
+		u = &url.URL{Scheme: scheme, Host: uri.Host, Path: "/api/v2/write", RawQuery: params.Encode()}
+		if token := qry.Get("token"); token != "" {
+			auth = "Token " + token
+		}
+	case qry.Get("db") != "":
+		params := url.Values{"db": {qry.Get("db")}, "precision": {"ns"}}
+		if rp := qry.Get("rp"); rp != "" {
+			params.Set("rp", rp)
+		}
+		u = &url.URL{Scheme: scheme, Host: uri.Host, Path: "/write", RawQuery: params.Encode()}
+	default:
+		return nil, errors.New("influx DSN requires a db, or a bucket and org")
+	}
+	endpoint := u.String()
+	user := uri.User
+
+	client := &http.Client{Timeout: influxWriteTimeout}
+	return func(p []byte) error {
+		reqCtx, cancel := context.WithTimeout(context.Background(), influxWriteTimeout)
+		defer cancel()
+
+		req, err := http.NewRequestWithContext(reqCtx, http.MethodPost, endpoint, bytes.NewReader(p))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		if user != nil {
+			pass, _ := user.Password()
+			req.SetBasicAuth(user.Username(), pass)
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = resp.Body.Close() }()
+
+		if resp.StatusCode/100 != 2 {
+			b, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+			return fmt.Errorf("unexpected influx status %d: %s", resp.StatusCode, bytes.TrimSpace(b))
+		}
+		return nil
+	}, nil
+}
+
+// influxFormat formats stats in the influx line protocol. The stats are
+// measurements with their tags as the tag set, and a single value field.
+type influxFormat struct{}
+
+func (f influxFormat) appendCounter(b []byte, name string, v int64, tags [][2]string, ts time.Time) []byte {
+	b = f.appendSeries(b, name, tags)
+	b = append(b, " value="...)
+	b = strconv.AppendInt(b, v, 10)
+	b = append(b, 'i')
+	return f.appendTime(b, ts)
+}
+
+func (f influxFormat) appendGauge(b []byte, name string, v float64, tags [][2]string, ts time.Time) []byte {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return b
+	}
+
+	b = f.appendSeries(b, name, tags)
+	b = append(b, " value="...)
+	b = strconv.AppendFloat(b, v, 'f', -1, 64)
+	return f.appendTime(b, ts)
+}
+
+// appendSeries appends the measurement and the tag set, sorted by key as recommended.
+func (influxFormat) appendSeries(b []byte, name string, tags [][2]string) []byte {
+	b = append(b, influxMeasurementEscaper.Replace(name)...)
+	if !slices.IsSortedFunc(tags, compareTags) {
+		tags = slices.SortedFunc(slices.Values(tags), compareTags)
+	}
+	for _, tag := range tags {
+		if tag[0] == "" || tag[1] == "" {
+			// Empty tag keys and values are not allowed.
+			continue
+		}
+		b = append(b, ',')
+		b = append(b, influxTagEscaper.Replace(tag[0])...)
+		b = append(b, '=')
+		b = append(b, influxTagEscaper.Replace(tag[1])...)
+	}
+	return b
+}
+
+func (influxFormat) appendTime(b []byte, ts time.Time) []byte {
+	b = append(b, ' ')
+	b = strconv.AppendInt(b, ts.UnixNano(), 10)
+	return append(b, '\n')
+}
+
+func compareTags(a, b [2]string) int {
+	return strings.Compare(a[0], b[0])
+}
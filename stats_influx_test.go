@@ -0,0 +1,130 @@
+package cmd_test
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hamba/logger/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewStatter_InfluxUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	lines := readPackets(conn)
+
+	log := logger.New(io.Discard, logger.LogfmtFormat(), logger.Error)
+	stats := newServerStatter(t, log, "influx+udp://"+conn.LocalAddr().String(), "--stats.prefix=app", "--stats.tags=zone=eu west,env=prod", "--stats.interval=1h")
+
+	stats.Counter("requests").Inc(2)
+	stats.Gauge("queue,size").Set(1.5)
+	require.NoError(t, stats.Close())
+
+	got := readLines(t, lines, 2)
+	want := []string{
+		`app.requests,env=prod,zone=eu\ west value=2i`,
+		`app.queue\,size,env=prod,zone=eu\ west value=1.5`,
+	}
+	assert.ElementsMatch(t, want, trimTimestamps(t, got, `\d{19}`))
+}
+
+func TestNewStatter_InfluxHTTP(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		wantPath string
+		wantAuth string
+	}{
+		{
+			name:     "v1",
+			query:    "db=stats&rp=week",
+			wantPath: "/write?db=stats&precision=ns&rp=week",
+		},
+		{
+			name:     "v2",
+			query:    "org=my-org&bucket=stats&token=secret",
+			wantPath: "/api/v2/write?bucket=stats&org=my-org&precision=ns",
+			wantAuth: "Token secret",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var gotPath, gotAuth, gotBody string
+			srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				b, _ := io.ReadAll(req.Body)
+				gotPath, gotAuth, gotBody = req.URL.RequestURI(), req.Header.Get("Authorization"), string(b)
+				rw.WriteHeader(http.StatusNoContent)
+			}))
+			t.Cleanup(srv.Close)
+
+			log := logger.New(io.Discard, logger.LogfmtFormat(), logger.Error)
+			dsn := "influx+http://" + strings.TrimPrefix(srv.URL, "http://") + "?" + test.query
+			stats := newServerStatter(t, log, dsn, "--stats.interval=1h")
+
+			stats.Counter("requests").Inc(3)
+			require.NoError(t, stats.Close())
+
+			assert.Equal(t, test.wantPath, gotPath)
+			assert.Equal(t, test.wantAuth, gotAuth)
+			assert.Equal(t, []string{"requests value=3i"}, trimTimestamps(t, []string{strings.TrimSuffix(gotBody, "\n")}, `\d{19}`))
+		})
+	}
+}
+
+func TestNewStatter_InfluxErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		dsn  string
+	}{
+		{
+			name: "no host",
+			dsn:  "influx+udp://",
+		},
+		{
+			name: "no database",
+			dsn:  "influx+http://localhost:8086",
+		},
+		{
+			name: "bucket without org",
+			dsn:  "influx+http://localhost:8086?bucket=stats",
+		},
+		{
+			name: "invalid flush interval",
+			dsn:  "influx+udp://localhost:8089?flushInterval=0s",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := runStatter(t, "--stats.dsn="+test.dsn)
+
+			assert.Error(t, err)
+		})
+	}
+}
+
+// readPackets reads the lines of the packets received on the connection.
+func readPackets(conn net.PacketConn) <-chan string {
+	lines := make(chan string, 10)
+	go func() {
+		buf := make([]byte, 64*1024)
+		for {
+			n, _, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			for line := range strings.SplitSeq(strings.TrimSuffix(string(buf[:n]), "\n"), "\n") {
+				lines <- line
+			}
+		}
+	}()
+	return lines
+}
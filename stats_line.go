@@ -0,0 +1,200 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hamba/logger/v2"
+	"github.com/hamba/logger/v2/ctx"
+)
+
+// Line reporter defaults.
+const (
+	defaultLineFlushInterval = time.Second
+	defaultLineFlushBytes    = 64 * 1024
+	maxLinePacketSize        = 1432
+)
+
+// lineFormat formats stats as lines of a text protocol.
+type lineFormat interface {
+	appendCounter(b []byte, name string, v int64, tags [][2]string, ts time.Time) []byte
+	appendGauge(b []byte, name string, v float64, tags [][2]string, ts time.Time) []byte
+}
+
+// lineOptions are the options of a line reporter.
+type lineOptions struct {
+	flushInterval time.Duration
+	flushBytes    int
+}
+
+// parseLineOptions parses the `flushInterval` and `flushBytes` DSN options.
+func parseLineOptions(qry url.Values) (lineOptions, error) {
+	opts := lineOptions{flushInterval: defaultLineFlushInterval, flushBytes: defaultLineFlushBytes}
+	if s := qry.Get("flushInterval"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil || d <= 0 {
+			return lineOptions{}, fmt.Errorf("invalid flushInterval %q", s)
+		}
+		opts.flushInterval = d
+	}
+	if s := qry.Get("flushBytes"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			return lineOptions{}, fmt.Errorf("invalid flushBytes %q", s)
+		}
+		opts.flushBytes = n
+	}
+	return opts, nil
+}
+
+// lineStats reports stats as lines of a text protocol. The lines are buffered,
+// and sent when the buffer reaches the flush bytes or at the flush interval.
+type lineStats struct {
+	fmt    lineFormat
+	send   func(p []byte) error
+	closer io.Closer
+	name   string
+	log    *logger.Logger
+
+	flushBytes int
+
+	mu  sync.Mutex
+	buf []byte
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+func newLineStats(name string, f lineFormat, send func([]byte) error, closer io.Closer, opts lineOptions, log *logger.Logger) *lineStats {
+	s := &lineStats{
+		fmt:        f,
+		send:       send,
+		closer:     closer,
+		name:       name,
+		log:        log,
+		flushBytes: opts.flushBytes,
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+
+	go s.run(opts.flushInterval)
+
+	return s
+}
+
+func (s *lineStats) run(intv time.Duration) {
+	defer close(s.done)
+
+	tick := time.NewTicker(intv)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-tick.C:
+			s.mu.Lock()
+			err := s.flush()
+			s.mu.Unlock()
+			if err != nil {
+				s.log.Error("Could not send stats", ctx.Str("server", s.name), ctx.Err(err))
+			}
+		}
+	}
+}
+
+// Counter reports a counter value.
+func (s *lineStats) Counter(name string, v int64, tags [][2]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.buf = s.fmt.appendCounter(s.buf, name, v, tags, time.Now())
+	s.flushIfFull()
+}
+
+// Gauge reports a gauge value.
+func (s *lineStats) Gauge(name string, v float64, tags [][2]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.buf = s.fmt.appendGauge(s.buf, name, v, tags, time.Now())
+	s.flushIfFull()
+}
+
+func (s *lineStats) flushIfFull() {
+	if len(s.buf) < s.flushBytes {
+		return
+	}
+	if err := s.flush(); err != nil {
+		s.log.Error("Could not send stats", ctx.Str("server", s.name), ctx.Err(err))
+	}
+}
+
+// flush sends the buffered lines. The lock must be held.
+func (s *lineStats) flush() error {
+	if len(s.buf) == 0 {
+		return nil
+	}
+
+	err := s.send(s.buf)
+	s.buf = s.buf[:0]
+	return err
+}
+
+// Close sends the buffered lines and closes the connection.
+func (s *lineStats) Close() error {
+	var err error
+	s.once.Do(func() {
+		close(s.stop)
+		<-s.done
+
+		s.mu.Lock()
+		err = s.flush()
+		s.mu.Unlock()
+
+		if s.closer != nil {
+			err = errors.Join(err, s.closer.Close())
+		}
+	})
+	return err
+}
+
+// streamSender sends lines to a stream connection.
+func streamSender(w io.Writer) func([]byte) error {
+	return func(p []byte) error {
+		_, err := w.Write(p)
+		return err
+	}
+}
+
+// packetSender sends lines to a packet connection, splitting
+// them into packets of at most size bytes where possible.
+func packetSender(w io.Writer, size int) func([]byte) error {
+	return func(p []byte) error {
+		for len(p) > 0 {
+			n := len(p)
+			if n > size {
+				// Split at the last line that fits, or after the first
+				// line when it is too long to fit in a packet.
+				if i := bytes.LastIndexByte(p[:size], '\n'); i >= 0 {
+					n = i + 1
+				} else if i = bytes.IndexByte(p, '\n'); i >= 0 {
+					n = i + 1
+				}
+			}
+
+			if _, err := w.Write(p[:n]); err != nil {
+				return err
+			}
+			p = p[n:]
+		}
+		return nil
+	}
+}
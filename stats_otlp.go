@@ -0,0 +1,198 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+)
+
+// otlpTimingBuckets are the histogram buckets of timings, in seconds.
+var otlpTimingBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// otlpStats reports stats as OTLP metrics.
+//
+// Timings are reported as histograms in seconds.
+type otlpStats struct {
+	prov  *sdkmetric.MeterProvider
+	meter metric.Meter
+
+	counters   sync.Map // map[string]metric.Int64Counter
+	gauges     sync.Map // map[string]metric.Float64Gauge
+	histograms sync.Map // map[string]metric.Float64Histogram
+	timings    sync.Map // map[string]metric.Float64Histogram
+}
+
+// newOTLPStats returns an OTLP stats reporter for the DSN, exporting
+// the metrics at the given interval with the resource attributes.
+func newOTLPStats(uri *url.URL, intv time.Duration, attrs []attribute.KeyValue) (*otlpStats, error) {
+	opts, err := parseOTLPOptions(uri.Query())
+	if err != nil {
+		return nil, err
+	}
+
+	exp, err := createStatsExporter(uri, opts.insecure, opts.headers)
+	if err != nil {
+		return nil, err
+	}
+
+	prov := sdkmetric.NewMeterProvider(
+		sdkmetric.WithResource(resource.NewWithAttributes(semconv.SchemaURL, attrs...)),
+		sdkmetric.WithReader(sdkmetric.NewPeriodicReader(exp, sdkmetric.WithInterval(intv))),
+	)
+	return &otlpStats{
+		prov:  prov,
+		meter: prov.Meter("github.com/hamba/cmd"),
+	}, nil
+}
+
+type otlpOptions struct {
+	insecure bool
+	headers  map[string]string
+}
+
+// parseOTLPOptions parses the OTLP DSN query, which can set `insecure`
+// and `headers`, in the form `key=value`, which can be repeated.
+func parseOTLPOptions(qry url.Values) (otlpOptions, error) {
+	opts := otlpOptions{headers: make(map[string]string, len(qry["headers"]))}
+
+	var errs []error
+	for _, key := range slices.Sorted(maps.Keys(qry)) {
+		switch key {
+		case "insecure":
+			s := qry.Get(key)
+			insecure, err := strconv.ParseBool(s)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("invalid insecure %q: must be a boolean", s))
+				continue
+			}
+			opts.insecure = insecure
+		case "headers":
+			for _, h := range qry[key] {
+				k, v, ok := strings.Cut(h, "=")
+				if !ok || k == "" {
+					errs = append(errs, fmt.Errorf("invalid header %q, expected key=value", h))
+					continue
+				}
+				opts.headers[k] = v
+			}
+		default:
+			errs = append(errs, fmt.Errorf("unknown otlp option %q", key))
+		}
+	}
+	if len(errs) > 0 {
+		return otlpOptions{}, errors.Join(errs...)
+	}
+	return opts, nil
+}
+
+func createStatsExporter(uri *url.URL, insecure bool, headers map[string]string) (sdkmetric.Exporter, error) {
+	ctx := context.Background()
+
+	switch uri.Scheme {
+	case "otlphttp":
+		opts := []otlpmetrichttp.Option{otlpmetrichttp.WithHeaders(headers)}
+		if uri.Host != "" {
+			opts = append(opts, otlpmetrichttp.WithEndpoint(uri.Host))
+		}
+		if uri.Path != "" && uri.Path != "/" {
+			opts = append(opts, otlpmetrichttp.WithURLPath(uri.Path))
+		}
+		if insecure {
+			opts = append(opts, otlpmetrichttp.WithInsecure())
+		}
+		return otlpmetrichttp.New(ctx, opts...)
+	case "otlpgrpc":
+		opts := []otlpmetricgrpc.Option{otlpmetricgrpc.WithHeaders(headers)}
+		if uri.Host != "" {
+			opts = append(opts, otlpmetricgrpc.WithEndpoint(uri.Host))
+		}
+		if insecure {
+			opts = append(opts, otlpmetricgrpc.WithInsecure())
+		}
+		return otlpmetricgrpc.New(ctx, opts...)
+	default:
+		return nil, errors.New("unsupported otlp scheme: " + uri.Scheme)
+	}
+}
+
+// Counter reports a counter value.
+func (s *otlpStats) Counter(name string, v int64, tags [][2]string) {
+	c := loadInstrument(&s.counters, name, func() (metric.Int64Counter, error) {
+		return s.meter.Int64Counter(name)
+	})
+	c.Add(context.Background(), v, otlpAttributes(tags))
+}
+
+// Gauge reports a gauge value.
+func (s *otlpStats) Gauge(name string, v float64, tags [][2]string) {
+	g := loadInstrument(&s.gauges, name, func() (metric.Float64Gauge, error) {
+		return s.meter.Float64Gauge(name)
+	})
+	g.Record(context.Background(), v, otlpAttributes(tags))
+}
+
+// Histogram returns a histogram reporter.
+func (s *otlpStats) Histogram(name string, tags [][2]string) func(v float64) {
+	h := loadInstrument(&s.histograms, name, func() (metric.Float64Histogram, error) {
+		return s.meter.Float64Histogram(name)
+	})
+	attrs := otlpAttributes(tags)
+	return func(v float64) {
+		h.Record(context.Background(), v, attrs)
+	}
+}
+
+// Timing returns a timing reporter.
+func (s *otlpStats) Timing(name string, tags [][2]string) func(v time.Duration) {
+	h := loadInstrument(&s.timings, name, func() (metric.Float64Histogram, error) {
+		return s.meter.Float64Histogram(name, metric.WithUnit("s"), metric.WithExplicitBucketBoundaries(otlpTimingBuckets...))
+	})
+	attrs := otlpAttributes(tags)
+	return func(v time.Duration) {
+		h.Record(context.Background(), v.Seconds(), attrs)
+	}
+}
+
+// Close exports the remaining metrics and shuts down the exporter.
+func (s *otlpStats) Close() error {
+	return s.prov.Shutdown(context.Background())
+}
+
+// loadInstrument returns the instrument with the given name, creating it if needed.
+// Creation errors are handled by the OTel error handler.
+func loadInstrument[T any](m *sync.Map, name string, create func() (T, error)) T {
+	if inst, ok := m.Load(name); ok {
+		return inst.(T)
+	}
+
+	inst, err := create()
+	if err != nil {
+		otel.Handle(err)
+	}
+	actual, _ := m.LoadOrStore(name, inst)
+	return actual.(T)
+}
+
+func otlpAttributes(tags [][2]string) metric.MeasurementOption {
+	kvs := make([]attribute.KeyValue, len(tags))
+	for i, tag := range tags {
+		kvs[i] = attribute.String(tag[0], tag[1])
+	}
+	return metric.WithAttributeSet(attribute.NewSet(kvs...))
+}
@@ -0,0 +1,211 @@
+package cmd_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hamba/cmd/v3"
+	"github.com/hamba/logger/v2"
+	"github.com/hamba/statter/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v3"
+	"go.opentelemetry.io/otel/attribute"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestNewStatter_OTLP(t *testing.T) {
+	tests := []struct {
+		name    string
+		dsn     string
+		wantErr require.ErrorAssertionFunc
+	}{
+		{
+			name:    "otlphttp",
+			dsn:     "otlphttp://localhost:4318?headers=a=b",
+			wantErr: require.NoError,
+		},
+		{
+			name:    "otlpgrpc",
+			dsn:     "otlpgrpc://localhost:4317?insecure=true",
+			wantErr: require.NoError,
+		},
+		{
+			name:    "invalid insecure",
+			dsn:     "otlphttp://localhost:4318?insecure=maybe",
+			wantErr: require.Error,
+		},
+		{
+			name:    "invalid header",
+			dsn:     "otlphttp://localhost:4318?headers=a",
+			wantErr: require.Error,
+		},
+		{
+			name:    "unknown option",
+			dsn:     "otlpgrpc://localhost:4317?insecur=true",
+			wantErr: require.Error,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			log := logger.New(io.Discard, logger.LogfmtFormat(), logger.Error)
+
+			c := &cli.Command{
+				Flags: cmd.StatsFlags,
+				Action: func(_ context.Context, c *cli.Command) error {
+					_, err := cmd.NewStatter(c, log)
+					return err
+				},
+			}
+
+			err := c.Run(t.Context(), []string{"test", "--stats.dsn=" + test.dsn, "--stats.interval=1h"})
+
+			test.wantErr(t, err)
+		})
+	}
+}
+
+func TestNewStatter_OTLPExportsMetrics(t *testing.T) {
+	recv := &metricReceiver{}
+	srv := httptest.NewServer(recv)
+	t.Cleanup(srv.Close)
+
+	log := logger.New(io.Discard, logger.LogfmtFormat(), logger.Error)
+
+	var stats *statter.Statter
+	c := &cli.Command{
+		Flags: cmd.StatsFlags,
+		Action: func(_ context.Context, c *cli.Command) error {
+			var err error
+			stats, err = cmd.NewStatter(c, log)
+			return err
+		},
+	}
+
+	dsn := "otlphttp://" + strings.TrimPrefix(srv.URL, "http://") + "?insecure=true&headers=x-token=secret"
+	err := c.Run(t.Context(), []string{"test", "--stats.dsn=" + dsn, "--stats.prefix=app", "--stats.tags=env=test", "--stats.interval=1h"})
+	require.NoError(t, err)
+
+	stats.Counter("requests").Inc(2)
+	stats.Gauge("queue").Set(3)
+	stats.Histogram("size").Observe(4)
+	stats.Timing("latency").Observe(250 * time.Millisecond)
+	require.NoError(t, stats.Close())
+
+	assert.Equal(t, "secret", recv.Header().Get("X-Token"))
+	metrics := recv.Metrics()
+	require.Contains(t, metrics, "app.requests")
+	require.Contains(t, metrics, "app.queue")
+	require.Contains(t, metrics, "app.size")
+	require.Contains(t, metrics, "app.latency")
+
+	dp := metrics["app.requests"].GetSum().GetDataPoints()[0]
+	assert.Equal(t, int64(2), dp.GetAsInt())
+	require.Len(t, dp.GetAttributes(), 1)
+	assert.Equal(t, "env", dp.GetAttributes()[0].GetKey())
+	assert.Equal(t, "test", dp.GetAttributes()[0].GetValue().GetStringValue())
+	assert.InDelta(t, 3.0, metrics["app.queue"].GetGauge().GetDataPoints()[0].GetAsDouble(), 0.0001)
+	assert.InDelta(t, 4.0, metrics["app.size"].GetHistogram().GetDataPoints()[0].GetSum(), 0.0001)
+	assert.Equal(t, "s", metrics["app.latency"].GetUnit())
+	assert.InDelta(t, 0.25, metrics["app.latency"].GetHistogram().GetDataPoints()[0].GetSum(), 0.0001)
+}
+
+func TestNewStatterWithHandler_OTLPResource(t *testing.T) {
+	recv := &metricReceiver{}
+	srv := httptest.NewServer(recv)
+	t.Cleanup(srv.Close)
+
+	log := logger.New(io.Discard, logger.LogfmtFormat(), logger.Error)
+
+	var stats *statter.Statter
+	c := &cli.Command{
+		Flags: cmd.StatsFlags,
+		Action: func(_ context.Context, c *cli.Command) error {
+			var err error
+			stats, _, err = cmd.NewStatterWithHandler(c, log, attribute.String("service.name", "my-service"))
+			return err
+		},
+	}
+
+	dsn := "otlphttp://" + strings.TrimPrefix(srv.URL, "http://") + "?insecure=true"
+	err := c.Run(t.Context(), []string{"test", "--stats.dsn=" + dsn, "--stats.interval=1h"})
+	require.NoError(t, err)
+
+	stats.Counter("requests").Inc(1)
+	require.NoError(t, stats.Close())
+
+	attrs := recv.Resource().GetAttributes()
+	require.Len(t, attrs, 1)
+	assert.Equal(t, "service.name", attrs[0].GetKey())
+	assert.Equal(t, "my-service", attrs[0].GetValue().GetStringValue())
+}
+
+type metricReceiver struct {
+	mu      sync.Mutex
+	header  http.Header
+	res     *resourcepb.Resource
+	metrics map[string]*metricspb.Metric
+}
+
+func (r *metricReceiver) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	b, err := io.ReadAll(req.Body)
+	if err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var exp colmetricspb.ExportMetricsServiceRequest
+	if err = proto.Unmarshal(b, &exp); err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	r.mu.Lock()
+	r.header = req.Header
+	if r.metrics == nil {
+		r.metrics = map[string]*metricspb.Metric{}
+	}
+	for _, rm := range exp.GetResourceMetrics() {
+		r.res = rm.GetResource()
+		for _, sm := range rm.GetScopeMetrics() {
+			for _, m := range sm.GetMetrics() {
+				r.metrics[m.GetName()] = m
+			}
+		}
+	}
+	r.mu.Unlock()
+
+	b, _ = proto.Marshal(&colmetricspb.ExportMetricsServiceResponse{})
+	rw.Header().Set("Content-Type", "application/x-protobuf")
+	_, _ = rw.Write(b)
+}
+
+func (r *metricReceiver) Header() http.Header {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.header
+}
+
+func (r *metricReceiver) Resource() *resourcepb.Resource {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.res
+}
+
+func (r *metricReceiver) Metrics() map[string]*metricspb.Metric {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.metrics
+}
@@ -0,0 +1,185 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hamba/logger/v2"
+	"github.com/hamba/logger/v2/ctx"
+	"github.com/hamba/statter/v2/reporter/prometheus"
+)
+
+// pushTimeout is the timeout of a push to the pushgateway.
+const pushTimeout = 10 * time.Second
+
+// pushgatewayStats is a prometheus reporter pushing its metrics to a
+// pushgateway at an interval, and once more when closed.
+type pushgatewayStats struct {
+	*prometheus.Prometheus
+
+	url    string
+	user   *url.Userinfo
+	client *http.Client
+	log    *logger.Logger
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// newPushgatewayStats returns a pushgateway reporter for the DSN, in the form
+// `pushgateway://host:port/job`, grouping the metrics by the given labels.
+// With the `pushgateway+https` scheme, the metrics are pushed over HTTPS.
+func newPushgatewayStats(uri *url.URL, labels map[string]string, intv time.Duration, log *logger.Logger) (*pushgatewayStats, error) {
+	job := strings.Trim(uri.Path, "/")
+	if uri.Host == "" || job == "" {
+		return nil, errors.New("pushgateway DSN requires a host and job, e.g. pushgateway://host:port/job")
+	}
+
+	scheme := "http"
+	if uri.Scheme == "pushgateway+https" {
+		scheme = "https"
+	}
+
+	p := &pushgatewayStats{
+		Prometheus: prometheus.New(""),
+		url:        scheme + "://" + uri.Host + "/metrics" + pushGroupingPath(job, labels),
+		user:       uri.User,
+		client:     &http.Client{Timeout: pushTimeout},
+		log:        log,
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+
+	go p.run(intv)
+
+	return p, nil
+}
+
+func (p *pushgatewayStats) run(intv time.Duration) {
+	defer close(p.done)
+
+	tick := time.NewTicker(intv)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-tick.C:
+			if err := p.push(); err != nil {
+				p.log.Error("Could not push stats", ctx.Str("server", "pushgateway"), ctx.Err(err))
+			}
+		}
+	}
+}
+
+// push replaces the metrics of the group with the current metrics.
+func (p *pushgatewayStats) push() error {
+	var body bufferResponse
+	req, err := http.NewRequest(http.MethodGet, "/metrics", nil)
+	if err != nil {
+		return err
+	}
+	p.Handler().ServeHTTP(&body, req)
+	if body.status != 0 && body.status != http.StatusOK {
+		return fmt.Errorf("gathering metrics: status %d", body.status)
+	}
+
+	pushCtx, cancel := context.WithTimeout(context.Background(), pushTimeout)
+	defer cancel()
+
+	req, err = http.NewRequestWithContext(pushCtx, http.MethodPut, p.url, &body.buf)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", body.Header().Get("Content-Type"))
+	if p.user != nil {
+		pass, _ := p.user.Password()
+		req.SetBasicAuth(p.user.Username(), pass)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode/100 != 2 {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("unexpected pushgateway status %d: %s", resp.StatusCode, bytes.TrimSpace(b))
+	}
+	return nil
+}
+
+// Close stops the periodic pushes and pushes the final metrics.
+func (p *pushgatewayStats) Close() error {
+	var err error
+	p.once.Do(func() {
+		close(p.stop)
+		<-p.done
+
+		err = errors.Join(p.push(), p.Prometheus.Close())
+	})
+	return err
+}
+
+// pushGroupingPath returns the pushgateway path of the job and grouping labels.
+// Values that cannot be used in a path are base64 encoded.
+func pushGroupingPath(job string, labels map[string]string) string {
+	var sb strings.Builder
+	writeLabel := func(name, val string) {
+		sb.WriteByte('/')
+		sb.WriteString(url.PathEscape(name))
+		if val == "" || strings.Contains(val, "/") {
+			sb.WriteString("@base64/")
+			if val == "" {
+				sb.WriteByte('=')
+				return
+			}
+			sb.WriteString(base64.RawURLEncoding.EncodeToString([]byte(val)))
+			return
+		}
+		sb.WriteByte('/')
+		sb.WriteString(url.PathEscape(val))
+	}
+
+	writeLabel("job", job)
+	for _, name := range slices.Sorted(maps.Keys(labels)) {
+		writeLabel(name, labels[name])
+	}
+	return sb.String()
+}
+
+// bufferResponse is a response writer buffering the response.
+type bufferResponse struct {
+	header http.Header
+	status int
+	buf    bytes.Buffer
+}
+
+func (r *bufferResponse) Header() http.Header {
+	if r.header == nil {
+		r.header = http.Header{}
+	}
+	return r.header
+}
+
+func (r *bufferResponse) WriteHeader(status int) {
+	r.status = status
+}
+
+func (r *bufferResponse) Write(p []byte) (int, error) {
+	return r.buf.Write(p)
+}
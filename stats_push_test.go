@@ -0,0 +1,128 @@
+package cmd_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hamba/cmd/v3"
+	"github.com/hamba/logger/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v3"
+)
+
+func TestNewStatter_Pushgateway(t *testing.T) {
+	gw := &pushgateway{}
+	srv := httptest.NewServer(gw)
+	t.Cleanup(srv.Close)
+
+	log := logger.New(io.Discard, logger.LogfmtFormat(), logger.Error)
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	stats := newServerStatter(t, log, "pushgateway://user:pass@"+host+"/my-job", "--stats.tags=env=test,path=/a/b", "--stats.interval=20ms")
+	stats.Counter("requests").Inc(2)
+
+	assert.EventuallyWithT(t, func(c *assert.CollectT) {
+		pushes := gw.Pushes()
+		require.NotEmpty(c, pushes)
+		assert.Contains(c, pushes[len(pushes)-1].body, `requests{env="test",path="/a/b"} 2`)
+	}, time.Second, 10*time.Millisecond)
+
+	stats.Counter("requests").Inc(1)
+	require.NoError(t, stats.Close())
+
+	pushes := gw.Pushes()
+	last := pushes[len(pushes)-1]
+	assert.Equal(t, http.MethodPut, last.method)
+	assert.Equal(t, "/metrics/job/my-job/env/test/path@base64/L2EvYg", last.path)
+	assert.Equal(t, "user", last.user)
+	assert.Contains(t, last.body, `requests{env="test",path="/a/b"} 3`)
+}
+
+func TestNewStatter_PushgatewayHTTPS(t *testing.T) {
+	srv := httptest.NewServer(&pushgateway{})
+	t.Cleanup(srv.Close)
+
+	log := logger.New(io.Discard, logger.LogfmtFormat(), logger.Error)
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	stats := newServerStatter(t, log, "pushgateway+https://"+host+"/my-job", "--stats.interval=1h")
+	err := stats.Close()
+
+	// The server only speaks HTTP, so the push over HTTPS fails.
+	assert.ErrorContains(t, err, "https://"+host+"/metrics/job/my-job")
+}
+
+func TestNewStatter_PushgatewayErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		dsn  string
+	}{
+		{
+			name: "no job",
+			dsn:  "pushgateway://localhost:9091",
+		},
+		{
+			name: "no host",
+			dsn:  "pushgateway:///my-job",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			log := logger.New(io.Discard, logger.LogfmtFormat(), logger.Error)
+
+			c := &cli.Command{
+				Flags: cmd.StatsFlags,
+				Action: func(_ context.Context, c *cli.Command) error {
+					_, err := cmd.NewStatter(c, log)
+					return err
+				},
+			}
+
+			err := c.Run(t.Context(), []string{"test", "--stats.dsn=" + test.dsn})
+
+			assert.Error(t, err)
+		})
+	}
+}
+
+type push struct {
+	method string
+	path   string
+	user   string
+	body   string
+}
+
+type pushgateway struct {
+	mu     sync.Mutex
+	pushes []push
+}
+
+func (g *pushgateway) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	b, err := io.ReadAll(req.Body)
+	if err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	user, _, _ := req.BasicAuth()
+
+	g.mu.Lock()
+	g.pushes = append(g.pushes, push{method: req.Method, path: req.URL.EscapedPath(), user: user, body: string(b)})
+	g.mu.Unlock()
+
+	rw.WriteHeader(http.StatusOK)
+}
+
+func (g *pushgateway) Pushes() []push {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.pushes
+}
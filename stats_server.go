@@ -0,0 +1,210 @@
+package cmd
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"github.com/hamba/logger/v2"
+	"github.com/hamba/logger/v2/ctx"
+	"github.com/hamba/statter/v2/reporter/prometheus"
+	"github.com/hamba/statter/v2/reporter/victoriametrics"
+)
+
+// metricsShutdownTimeout is the time in-flight scrapes are given
+// to complete when a metrics server is shut down.
+const metricsShutdownTimeout = 5 * time.Second
+
+// defaultScrapeWindow is the default time to wait for a final scrape on
+// close. It catches the final stats of frequent scrapers, without holding
+// up the shutdown for long.
+const defaultScrapeWindow = 5 * time.Second
+
+// metricsServer serves the metrics of a reporter over HTTP.
+type metricsServer struct {
+	srv *http.Server
+
+	// window is the time to wait for a final scrape on close.
+	window  time.Duration
+	seen    atomic.Bool
+	closing atomic.Bool
+	scraped chan struct{}
+
+	done chan struct{}
+}
+
+// metricsServerOptions are the options of a metrics server.
+type metricsServerOptions struct {
+	// window is the time to wait for a final scrape on close.
+	window time.Duration
+	sec    serverSecurity
+}
+
+// parseMetricsServerOptions parses the metrics server DSN options, and the
+// basic auth credentials from the DSN user info. Unknown options are rejected,
+// so that typos do not go unnoticed, as are options without a host, as there
+// is then no server for them to apply to.
+func parseMetricsServerOptions(uri *url.URL) (metricsServerOptions, error) {
+	qry := uri.Query()
+	if uri.Host == "" {
+		if len(qry) > 0 || uri.User != nil {
+			return metricsServerOptions{}, errors.New("metrics server options and credentials require a host")
+		}
+		return metricsServerOptions{}, nil
+	}
+
+	var (
+		opts = metricsServerOptions{window: defaultScrapeWindow}
+		errs []error
+	)
+	for _, key := range slices.Sorted(maps.Keys(qry)) {
+		s := qry.Get(key)
+		switch key {
+		case "scrapeWindow":
+			d, err := time.ParseDuration(s)
+			if err != nil || d < 0 {
+				errs = append(errs, fmt.Errorf("invalid scrapeWindow %q", s))
+				continue
+			}
+			opts.window = d
+		case "tlsCert":
+			opts.sec.certFile = s
+		case "tlsKey":
+			opts.sec.keyFile = s
+		case "tlsClientCA":
+			opts.sec.clientCAFile = s
+		case "token":
+			opts.sec.token = s
+		default:
+			errs = append(errs, fmt.Errorf("unknown metrics server option %q", key))
+		}
+	}
+	if uri.User != nil {
+		opts.sec.user = uri.User.Username()
+		opts.sec.password, _ = uri.User.Password()
+		if opts.sec.user == "" || opts.sec.password == "" {
+			errs = append(errs, errors.New("invalid user info: must be in the form user:password"))
+		}
+	}
+	if err := opts.sec.validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) > 0 {
+		return metricsServerOptions{}, errors.Join(errs...)
+	}
+	return opts, nil
+}
+
+// startMetricsServer starts serving the metrics handler on addr. Listen
+// errors are returned, while later serve errors are logged.
+func startMetricsServer(addr string, h http.Handler, opts metricsServerOptions, name string, log *logger.Logger) (*metricsServer, error) {
+	tlsCfg, err := opts.sec.tlsConfig()
+	if err != nil {
+		return nil, fmt.Errorf("starting %s server: %w", name, err)
+	}
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("starting %s server: %w", name, err)
+	}
+	if tlsCfg != nil {
+		ln = tls.NewListener(ln, tlsCfg)
+	}
+
+	s := &metricsServer{
+		window:  opts.window,
+		scraped: make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", opts.sec.authenticate("metrics", s.trackScrapes(h)))
+	s.srv = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: time.Second,
+		ReadTimeout:       10 * time.Second,
+		WriteTimeout:      10 * time.Second,
+		IdleTimeout:       120 * time.Second,
+	}
+
+	go func() {
+		defer close(s.done)
+
+		if err := s.srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error(err.Error(), ctx.Str("server", name))
+		}
+	}()
+
+	return s, nil
+}
+
+// trackScrapes tracks if the server has been scraped, and
+// signals scrapes completed while the server is closing.
+func (s *metricsServer) trackScrapes(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		h.ServeHTTP(rw, req)
+
+		s.seen.Store(true)
+		if s.closing.Load() {
+			select {
+			case s.scraped <- struct{}{}:
+			default:
+			}
+		}
+	})
+}
+
+// Close waits for a final scrape, up to the scrape window, then shuts the
+// server down gracefully, giving in-flight scrapes time to complete. A server
+// that was never scraped has no scraper to wait for, so it is shut down at once.
+func (s *metricsServer) Close() error {
+	if s.window > 0 && s.seen.Load() {
+		s.closing.Store(true)
+
+		timer := time.NewTimer(s.window)
+		select {
+		case <-s.scraped:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), metricsShutdownTimeout)
+	defer cancel()
+
+	err := s.srv.Shutdown(shutdownCtx)
+	<-s.done
+	return err
+}
+
+// prometheusStats is a prometheus reporter serving its metrics.
+type prometheusStats struct {
+	*prometheus.Prometheus
+
+	srv *metricsServer
+}
+
+// Close shuts down the metrics server and closes the reporter.
+func (p prometheusStats) Close() error {
+	return errors.Join(p.srv.Close(), p.Prometheus.Close())
+}
+
+// victoriaMetricsStats is a victoria metrics reporter serving its metrics.
+type victoriaMetricsStats struct {
+	*victoriametrics.VictoriaMetrics
+
+	srv *metricsServer
+}
+
+// Close shuts down the metrics server and closes the reporter.
+func (v victoriaMetricsStats) Close() error {
+	return errors.Join(v.srv.Close(), v.VictoriaMetrics.Close())
+}
@@ -0,0 +1,225 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"math/rand/v2"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hamba/logger/v2"
+)
+
+// Statsd reporter defaults.
+const (
+	defaultStatsdFlushInterval = 300 * time.Millisecond
+	defaultStatsdFlushBytes    = 1432
+	defaultStatsdPacketSize    = 1432
+	defaultStatsdTags          = "influx"
+)
+
+// statsdEscaper replaces the characters that separate the parts of a statsd line.
+var statsdEscaper = strings.NewReplacer(":", "_", "|", "_", "@", "_", ",", "_", "#", "_", ";", "_", "=", "_", "\n", "_")
+
+// statsdTagFormats are the supported formats of statsd tags.
+var statsdTagFormats = map[string]statsdTags{
+	"influx":    {start: ",", sep: ",", kvSep: "="},
+	"graphite":  {start: ";", sep: ";", kvSep: "="},
+	"dogstatsd": {suffix: true, start: "|#", sep: ",", kvSep: ":"},
+}
+
+// newStatsdStats returns a statsd reporter for the DSN, either `statsd://host:port`
+// over udp, `statsd+tcp://host:port` or `statsd+unix:///path` to a datagram socket.
+//
+// The lines are written by the line reporter rather than the statter statsd
+// reporter, as it only sends over udp with influx style tags. This way statsd
+// shares the batching and the transports of the graphite and influx reporters.
+func newStatsdStats(uri *url.URL, log *logger.Logger) (*lineStats, error) {
+	opts, err := parseStatsdOptions(uri.Query())
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		conn *redialConn
+		send func([]byte) error
+	)
+	switch uri.Scheme {
+	case "statsd+unix":
+		if uri.Path == "" {
+			return nil, errors.New("statsd unix DSN requires a path")
+		}
+		conn = &redialConn{network: "unixgram", addr: uri.Path}
+		send = packetSender(conn, opts.packetSize)
+	case "statsd+tcp":
+		if uri.Host == "" {
+			return nil, errors.New("statsd DSN requires a host")
+		}
+		conn = &redialConn{network: "tcp", addr: uri.Host}
+		send = streamSender(conn)
+	default:
+		if uri.Host == "" {
+			return nil, errors.New("statsd DSN requires a host")
+		}
+		conn = &redialConn{network: "udp", addr: uri.Host}
+		send = packetSender(conn, opts.packetSize)
+	}
+
+	return newLineStats("statsd", opts.format, send, conn, opts.lineOptions, log), nil
+}
+
+// statsdOptions are the options of a statsd reporter.
+type statsdOptions struct {
+	lineOptions
+
+	format     statsdFormat
+	packetSize int
+}
+
+// parseStatsdOptions parses the statsd DSN options. Unknown
+// options are rejected, so that typos do not go unnoticed.
+func parseStatsdOptions(qry url.Values) (statsdOptions, error) {
+	opts := statsdOptions{
+		lineOptions: lineOptions{flushInterval: defaultStatsdFlushInterval, flushBytes: defaultStatsdFlushBytes},
+		format:      statsdFormat{tags: statsdTagFormats[defaultStatsdTags], rate: 1},
+		packetSize:  defaultStatsdPacketSize,
+	}
+
+	var errs []error
+	for _, key := range slices.Sorted(maps.Keys(qry)) {
+		s := qry.Get(key)
+		switch key {
+		case "tags":
+			tags, ok := statsdTagFormats[s]
+			if !ok {
+				errs = append(errs, fmt.Errorf("invalid tags %q: must be one of dogstatsd, influx, graphite", s))
+				continue
+			}
+			opts.format.tags = tags
+		case "prefix":
+			opts.format.prefix = s
+		case "sampleRate":
+			rate, err := strconv.ParseFloat(s, 64)
+			if err != nil || rate <= 0 || rate > 1 {
+				errs = append(errs, fmt.Errorf("invalid sampleRate %q: must be greater than 0 and at most 1", s))
+				continue
+			}
+			opts.format.rate = rate
+		case "flushInterval":
+			d, err := time.ParseDuration(s)
+			if err != nil || d <= 0 {
+				errs = append(errs, fmt.Errorf("invalid flushInterval %q: must be a positive duration", s))
+				continue
+			}
+			opts.flushInterval = d
+		case "flushBytes":
+			n, err := strconv.Atoi(s)
+			if err != nil || n <= 0 {
+				errs = append(errs, fmt.Errorf("invalid flushBytes %q: must be a positive integer", s))
+				continue
+			}
+			opts.flushBytes = n
+		case "maxPacketSize":
+			n, err := strconv.Atoi(s)
+			if err != nil || n <= 0 {
+				errs = append(errs, fmt.Errorf("invalid maxPacketSize %q: must be a positive integer", s))
+				continue
+			}
+			opts.packetSize = n
+		default:
+			errs = append(errs, fmt.Errorf("unknown statsd option %q", key))
+		}
+	}
+	if len(errs) > 0 {
+		return statsdOptions{}, errors.Join(errs...)
+	}
+	return opts, nil
+}
+
+// statsdTags is a format of statsd tags. The tags are added
+// after the name, or after the value when they are a suffix.
+type statsdTags struct {
+	suffix bool
+	start  string
+	sep    string
+	kvSep  string
+}
+
+// statsdFormat formats stats in the statsd protocol. Counters
+// are sampled at the rate, when it is less than one.
+//
+// The counters are aggregated before they are flushed, so the rate applies
+// to the flushed aggregates rather than to each increment. A sampled aggregate
+// is sent with its rate, which the server scales it back up by, so the
+// expected totals are kept while fewer lines are sent.
+type statsdFormat struct {
+	tags   statsdTags
+	prefix string
+	rate   float64
+}
+
+func (f statsdFormat) appendCounter(b []byte, name string, v int64, tags [][2]string, _ time.Time) []byte {
+	if f.rate < 1 && rand.Float64() >= f.rate {
+		return b
+	}
+
+	b = f.appendName(b, name, tags)
+	b = append(b, ':')
+	b = strconv.AppendInt(b, v, 10)
+	b = append(b, "|c"...)
+	if f.rate < 1 {
+		b = append(b, "|@"...)
+		b = strconv.AppendFloat(b, f.rate, 'f', -1, 64)
+	}
+	return f.appendEnd(b, tags)
+}
+
+func (f statsdFormat) appendGauge(b []byte, name string, v float64, tags [][2]string, _ time.Time) []byte {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return b
+	}
+
+	b = f.appendName(b, name, tags)
+	b = append(b, ':')
+	b = strconv.AppendFloat(b, v, 'f', -1, 64)
+	b = append(b, "|g"...)
+	return f.appendEnd(b, tags)
+}
+
+func (f statsdFormat) appendName(b []byte, name string, tags [][2]string) []byte {
+	if f.prefix != "" {
+		b = append(b, statsdEscaper.Replace(f.prefix)...)
+		b = append(b, '.')
+	}
+	b = append(b, statsdEscaper.Replace(name)...)
+	if !f.tags.suffix {
+		b = f.appendTags(b, tags)
+	}
+	return b
+}
+
+func (f statsdFormat) appendEnd(b []byte, tags [][2]string) []byte {
+	if f.tags.suffix {
+		b = f.appendTags(b, tags)
+	}
+	return append(b, '\n')
+}
+
+func (f statsdFormat) appendTags(b []byte, tags [][2]string) []byte {
+	for i, tag := range tags {
+		if i == 0 {
+			b = append(b, f.tags.start...)
+		} else {
+			b = append(b, f.tags.sep...)
+		}
+		b = append(b, statsdEscaper.Replace(tag[0])...)
+		b = append(b, f.tags.kvSep...)
+		b = append(b, statsdEscaper.Replace(tag[1])...)
+	}
+	return b
+}
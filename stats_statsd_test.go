@@ -0,0 +1,187 @@
+package cmd_test
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/hamba/logger/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewStatter_Statsd(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{
+			name: "default tags",
+			want: []string{"app.requests,env=prod:2|c", "app.queue_size,env=prod:1.5|g"},
+		},
+		{
+			name:  "influx tags",
+			query: "?tags=influx",
+			want:  []string{"app.requests,env=prod:2|c", "app.queue_size,env=prod:1.5|g"},
+		},
+		{
+			name:  "graphite tags",
+			query: "?tags=graphite",
+			want:  []string{"app.requests;env=prod:2|c", "app.queue_size;env=prod:1.5|g"},
+		},
+		{
+			name:  "dogstatsd tags",
+			query: "?tags=dogstatsd",
+			want:  []string{"app.requests:2|c|#env:prod", "app.queue_size:1.5|g|#env:prod"},
+		},
+		{
+			name:  "prefix",
+			query: "?prefix=svc&tags=dogstatsd",
+			want:  []string{"svc.app.requests:2|c|#env:prod", "svc.app.queue_size:1.5|g|#env:prod"},
+		},
+		{
+			name:  "sample rate",
+			query: "?sampleRate=1&maxPacketSize=512&flushBytes=64&flushInterval=10ms",
+			want:  []string{"app.requests,env=prod:2|c", "app.queue_size,env=prod:1.5|g"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+			require.NoError(t, err)
+			t.Cleanup(func() { _ = conn.Close() })
+
+			lines := readPackets(conn)
+
+			log := logger.New(io.Discard, logger.LogfmtFormat(), logger.Error)
+			stats := newServerStatter(t, log, "statsd://"+conn.LocalAddr().String()+test.query, "--stats.prefix=app", "--stats.tags=env=prod", "--stats.interval=1h")
+
+			stats.Counter("requests").Inc(2)
+			stats.Gauge("queue_size").Set(1.5)
+			require.NoError(t, stats.Close())
+
+			got := readLines(t, lines, 2)
+			assert.ElementsMatch(t, test.want, got)
+		})
+	}
+}
+
+func TestNewStatter_StatsdSampleRate(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	lines := readPackets(conn)
+
+	log := logger.New(io.Discard, logger.LogfmtFormat(), logger.Error)
+	stats := newServerStatter(t, log, "statsd://"+conn.LocalAddr().String()+"?sampleRate=0.5", "--stats.interval=1h")
+
+	// Each counter is sent with a probability of one half, so some are sent.
+	for i := range 64 {
+		stats.Counter("requests" + strconv.Itoa(i)).Inc(1)
+	}
+	require.NoError(t, stats.Close())
+
+	got := readLines(t, lines, 1)
+	assert.Regexp(t, `^requests\d+:1\|c\|@0\.5$`, got[0])
+}
+
+func TestNewStatter_StatsdTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = ln.Close() })
+
+	lines := make(chan string, 10)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+
+		sc := bufio.NewScanner(conn)
+		for sc.Scan() {
+			lines <- sc.Text()
+		}
+	}()
+
+	log := logger.New(io.Discard, logger.LogfmtFormat(), logger.Error)
+	stats := newServerStatter(t, log, "statsd+tcp://"+ln.Addr().String()+"?tags=dogstatsd", "--stats.tags=env=prod", "--stats.interval=1h")
+
+	stats.Counter("requests").Inc(2)
+	require.NoError(t, stats.Close())
+
+	got := readLines(t, lines, 1)
+	assert.Equal(t, []string{"requests:2|c|#env:prod"}, got)
+}
+
+func TestNewStatter_StatsdUnix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dsd.socket")
+	conn, err := net.ListenPacket("unixgram", path)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	lines := readPackets(conn)
+
+	log := logger.New(io.Discard, logger.LogfmtFormat(), logger.Error)
+	stats := newServerStatter(t, log, "statsd+unix://"+path+"?tags=dogstatsd", "--stats.tags=env=prod,team=core", "--stats.interval=1h")
+
+	stats.Counter("requests").Inc(2)
+	require.NoError(t, stats.Close())
+
+	got := readLines(t, lines, 1)
+	assert.Equal(t, []string{"requests:2|c|#env:prod,team:core"}, got)
+}
+
+func TestNewStatter_StatsdErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		dsn  string
+	}{
+		{
+			name: "unsupported tags format",
+			dsn:  "statsd://localhost:8125?tags=prometheus",
+		},
+		{
+			name: "invalid flush bytes",
+			dsn:  "statsd://localhost:8125?flushBytes=big",
+		},
+		{
+			name: "invalid flush interval",
+			dsn:  "statsd://localhost:8125?flushInterval=soon",
+		},
+		{
+			name: "invalid sample rate",
+			dsn:  "statsd://localhost:8125?sampleRate=2",
+		},
+		{
+			name: "invalid max packet size",
+			dsn:  "statsd://localhost:8125?maxPacketSize=0",
+		},
+		{
+			name: "unknown option",
+			dsn:  "statsd://localhost:8125?flushByte=1432",
+		},
+		{
+			name: "no host",
+			dsn:  "statsd+tcp:///",
+		},
+		{
+			name: "no path",
+			dsn:  "statsd+unix://",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := runStatter(t, "--stats.dsn="+test.dsn)
+
+			assert.Error(t, err)
+		})
+	}
+}
@@ -1,13 +1,19 @@
 package cmd_test
 
 import (
+	"bytes"
 	"context"
 	"io"
+	"net"
+	"net/http"
 	"testing"
+	"time"
 
 	"github.com/hamba/cmd/v3"
 	"github.com/hamba/logger/v2"
+	"github.com/hamba/statter/v2"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/urfave/cli/v3"
 )
 
@@ -86,8 +92,11 @@ func TestNewStatter(t *testing.T) {
 			c := &cli.Command{
 				Flags: cmd.StatsFlags,
 				Action: func(_ context.Context, c *cli.Command) error {
-					_, err := cmd.NewStatter(c, log)
-					return err
+					stats, err := cmd.NewStatter(c, log)
+					if err != nil {
+						return err
+					}
+					return stats.Close()
 				},
 			}
 
@@ -97,3 +106,196 @@ func TestNewStatter(t *testing.T) {
 		})
 	}
 }
+
+func TestNewStatter_MultipleDSNs(t *testing.T) {
+	var buf bytes.Buffer
+	log := logger.New(&buf, logger.LogfmtFormat(), logger.Info)
+	addr := freeAddr(t)
+
+	var stats *statter.Statter
+	c := &cli.Command{
+		Flags: cmd.StatsFlags,
+		Action: func(_ context.Context, c *cli.Command) error {
+			var err error
+			stats, err = cmd.NewStatter(c, log)
+			return err
+		},
+	}
+
+	err := c.Run(t.Context(), []string{"test", "--stats.dsn=l2met://", "--stats.dsn=prom://" + addr + "?scrapeWindow=0s", "--stats.interval=10ms"})
+	require.NoError(t, err)
+
+	stats.Counter("requests").Inc(2)
+	stats.Timing("latency").Observe(time.Second)
+
+	var body string
+	assert.EventuallyWithT(t, func(c *assert.CollectT) {
+		body = scrape(c, addr)
+		assert.Contains(c, body, "requests 2")
+	}, time.Second, 10*time.Millisecond)
+	assert.Contains(t, body, "latency_bucket")
+
+	require.NoError(t, stats.Close())
+	assert.Contains(t, buf.String(), "count#requests=2")
+	assert.Contains(t, buf.String(), "sample#latency_")
+}
+
+func TestNewStatter_ServerListenError(t *testing.T) {
+	log := logger.New(io.Discard, logger.LogfmtFormat(), logger.Error)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = ln.Close() })
+
+	for _, scheme := range []string{"prom", "vm"} {
+		t.Run(scheme, func(t *testing.T) {
+			c := &cli.Command{
+				Flags: cmd.StatsFlags,
+				Action: func(_ context.Context, c *cli.Command) error {
+					_, err := cmd.NewStatter(c, log)
+					return err
+				},
+			}
+
+			err := c.Run(t.Context(), []string{"test", "--stats.dsn=" + scheme + "://" + ln.Addr().String()})
+
+			assert.ErrorContains(t, err, "address already in use")
+		})
+	}
+}
+
+func TestNewStatter_ServerShutdown(t *testing.T) {
+	log := logger.New(io.Discard, logger.LogfmtFormat(), logger.Error)
+
+	for _, scheme := range []string{"prom", "vm"} {
+		t.Run(scheme, func(t *testing.T) {
+			addr := freeAddr(t)
+
+			stats := newServerStatter(t, log, scheme+"://"+addr+"?scrapeWindow=0s")
+			assert.EventuallyWithT(t, func(c *assert.CollectT) {
+				scrape(c, addr)
+			}, time.Second, 10*time.Millisecond)
+
+			require.NoError(t, stats.Close())
+
+			ln, err := net.Listen("tcp", addr)
+			require.NoError(t, err)
+			_ = ln.Close()
+		})
+	}
+}
+
+func TestNewStatter_ServerScrapeWindow(t *testing.T) {
+	log := logger.New(io.Discard, logger.LogfmtFormat(), logger.Error)
+	addr := freeAddr(t)
+
+	stats := newServerStatter(t, log, "prom://"+addr+"?scrapeWindow=10s", "--stats.interval=1h")
+	scrape(t, addr)
+	stats.Counter("requests").Inc(3)
+
+	closed := make(chan error, 1)
+	go func() { closed <- stats.Close() }()
+
+	var body string
+	assert.EventuallyWithT(t, func(c *assert.CollectT) {
+		select {
+		case err := <-closed:
+			require.NoError(t, err)
+			return
+		default:
+		}
+		body = scrape(c, addr)
+		assert.Fail(c, "close has not returned")
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Contains(t, body, "requests 3")
+}
+
+func TestNewStatter_InvalidScrapeWindow(t *testing.T) {
+	log := logger.New(io.Discard, logger.LogfmtFormat(), logger.Error)
+
+	c := &cli.Command{
+		Flags: cmd.StatsFlags,
+		Action: func(_ context.Context, c *cli.Command) error {
+			_, err := cmd.NewStatter(c, log)
+			return err
+		},
+	}
+
+	err := c.Run(t.Context(), []string{"test", "--stats.dsn=prom://" + freeAddr(t) + "?scrapeWindow=soon"})
+
+	assert.Error(t, err)
+}
+
+func newServerStatter(t *testing.T, log *logger.Logger, dsn string, args ...string) *statter.Statter {
+	t.Helper()
+
+	var stats *statter.Statter
+	c := &cli.Command{
+		Flags: cmd.StatsFlags,
+		Action: func(_ context.Context, c *cli.Command) error {
+			var err error
+			stats, err = cmd.NewStatter(c, log)
+			return err
+		},
+	}
+
+	err := c.Run(t.Context(), append([]string{"test", "--stats.dsn=" + dsn}, args...))
+	require.NoError(t, err)
+
+	return stats
+}
+
+func runStatter(t *testing.T, args ...string) error {
+	t.Helper()
+
+	log := logger.New(io.Discard, logger.LogfmtFormat(), logger.Error)
+	c := &cli.Command{
+		Flags: cmd.StatsFlags,
+		Action: func(_ context.Context, c *cli.Command) error {
+			stats, err := cmd.NewStatter(c, log)
+			if err != nil {
+				return err
+			}
+			return stats.Close()
+		},
+	}
+
+	return c.Run(t.Context(), append([]string{"test"}, args...))
+}
+
+func scrape(c require.TestingT, addr string) string {
+	resp, err := http.Get("http://" + addr + "/metrics")
+	require.NoError(c, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	b, err := io.ReadAll(resp.Body)
+	require.NoError(c, err)
+	return string(b)
+}
+
+func TestNewStatter_MultipleDSNsError(t *testing.T) {
+	log := logger.New(io.Discard, logger.LogfmtFormat(), logger.Error)
+
+	c := &cli.Command{
+		Flags: cmd.StatsFlags,
+		Action: func(_ context.Context, c *cli.Command) error {
+			_, err := cmd.NewStatter(c, log)
+			return err
+		},
+	}
+
+	err := c.Run(t.Context(), []string{"test", "--stats.dsn=l2met://,unknownscheme://"})
+
+	assert.Error(t, err)
+}
+
+func freeAddr(t *testing.T) string {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := ln.Addr().String()
+	require.NoError(t, ln.Close())
+
+	return addr
+}
@@ -0,0 +1,240 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+
+	"github.com/urfave/cli/v3"
+)
+
+// FlagError is an error in the value of a flag.
+type FlagError struct {
+	Flag   string
+	EnvVar string
+	Err    error
+}
+
+// Error returns the error message, naming the flag and its env var.
+func (e *FlagError) Error() string {
+	if e.EnvVar == "" {
+		return fmt.Sprintf("invalid --%s: %v", e.Flag, e.Err)
+	}
+	return fmt.Sprintf("invalid --%s ($%s): %v", e.Flag, e.EnvVar, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *FlagError) Unwrap() error {
+	return e.Err
+}
+
+// ValidateFlags validates the monitoring flags set on the command,
+// returning a FlagError for each invalid flag.
+//
+// ValidateFlags can be used as the Before function of the command.
+func ValidateFlags(ctx context.Context, cmd *cli.Command) (context.Context, error) {
+	var (
+		errs []error
+		seen = map[string]bool{}
+	)
+	for _, c := range cmd.Lineage() {
+		for _, flag := range c.Flags {
+			name := flag.Names()[0]
+			validate, ok := flagValidators[name]
+			if !ok || seen[name] || !cmd.IsSet(name) {
+				continue
+			}
+			seen[name] = true
+
+			if err := validate(cmd); err != nil {
+				var envVar string
+				if f, ok := flag.(interface{ GetEnvVars() []string }); ok && len(f.GetEnvVars()) > 0 {
+					envVar = f.GetEnvVars()[0]
+				}
+				errs = append(errs, &FlagError{Flag: name, EnvVar: envVar, Err: err})
+			}
+		}
+	}
+	return ctx, errors.Join(errs...)
+}
+
+// flagValidators validate the value of the flags by name.
+var flagValidators = map[string]func(cmd *cli.Command) error{
+	FlagLogFormat: func(cmd *cli.Command) error {
+		_, err := newLogFormatter(cmd)
+		return err
+	},
+	FlagLogLevel: func(cmd *cli.Command) error {
+		_, _, err := parseLogLevel(cmd.String(FlagLogLevel))
+		return err
+	},
+	FlagLogRedact: func(cmd *cli.Command) error {
+		_, err := parseRedactor(cmd.StringSlice(FlagLogRedact))
+		return err
+	},
+	FlagLogSample: func(cmd *cli.Command) error {
+		_, err := parseLogSampler(cmd.StringMap(FlagLogSample))
+		return err
+	},
+	FlagLogOutput: func(cmd *cli.Command) error {
+		if cmd.String(FlagLogFile) != "" && cmd.String(FlagLogOutput) != "stdout" {
+			return errors.New("cannot be combined with --" + FlagLogFile)
+		}
+		return validateLogTarget(cmd.String(FlagLogOutput))
+	},
+	FlagLogSink: func(cmd *cli.Command) error {
+		var errs []error
+		if cmd.String(FlagLogOutput) != "" || cmd.String(FlagLogFile) != "" {
+			errs = append(errs, fmt.Errorf("cannot be combined with --%s or --%s", FlagLogOutput, FlagLogFile))
+		}
+		// The sinks are referred to by their position, as they may contain credentials.
+		for i, spec := range cmd.StringSlice(FlagLogSink) {
+			if _, _, err := parseLogSink(cmd, spec, nil); err != nil {
+				errs = append(errs, fmt.Errorf("sink %d: %w", i+1, err))
+			}
+		}
+		return errors.Join(errs...)
+	},
+	FlagLogMaxSize:    nonNegativeInt(FlagLogMaxSize),
+	FlagLogMaxBackups: nonNegativeInt(FlagLogMaxBackups),
+	FlagLogRotateInterval: func(cmd *cli.Command) error {
+		if cmd.Duration(FlagLogRotateInterval) < 0 {
+			return errors.New("must not be negative")
+		}
+		return nil
+	},
+	FlagLogMaxAge: func(cmd *cli.Command) error {
+		if cmd.Duration(FlagLogMaxAge) < 0 {
+			return errors.New("must not be negative")
+		}
+		return nil
+	},
+	FlagLogAsyncQueueSize: func(cmd *cli.Command) error {
+		if cmd.Int(FlagLogAsyncQueueSize) <= 0 {
+			return errors.New("must be positive")
+		}
+		return nil
+	},
+	FlagLogAsyncPolicy: func(cmd *cli.Command) error {
+		_, err := parseAsyncPolicy(cmd.String(FlagLogAsyncPolicy))
+		return err
+	},
+	FlagLogExporter: oneOf(FlagLogExporter, "otlphttp", "otlpgrpc"),
+	FlagStatsDSN: func(cmd *cli.Command) error {
+		dsns := statsDSNs(cmd)
+		if len(dsns) == 1 {
+			return validateStatsDSN(dsns[0])
+		}
+
+		// The DSNs are referred to by their position, as they may contain credentials.
+		var errs []error
+		for i, dsn := range dsns {
+			if err := validateStatsDSN(dsn); err != nil {
+				errs = append(errs, fmt.Errorf("dsn %d: %w", i+1, err))
+			}
+		}
+		return errors.Join(errs...)
+	},
+	FlagStatsInterval: func(cmd *cli.Command) error {
+		if cmd.Duration(FlagStatsInterval) <= 0 {
+			return errors.New("must be positive")
+		}
+		return nil
+	},
+	FlagStatsRuntimeInterval: func(cmd *cli.Command) error {
+		if cmd.Duration(FlagStatsRuntimeInterval) <= 0 {
+			return errors.New("must be positive")
+		}
+		return nil
+	},
+	FlagProfilingDSN: func(cmd *cli.Command) error {
+		if dsn := cmd.String(FlagProfilingDSN); dsn != "" {
+			_, err := parseProfilingDSN(dsn)
+			return err
+		}
+		return nil
+	},
+	FlagProfileUploadRate: func(cmd *cli.Command) error {
+		if cmd.Duration(FlagProfileUploadRate) <= 0 {
+			return errors.New("must be positive")
+		}
+		return nil
+	},
+	FlagProfilingTypes: func(cmd *cli.Command) error {
+		_, err := parseProfilingTypes(cmd.StringSlice(FlagProfilingTypes))
+		return err
+	},
+	FlagTracingExporter: oneOf(FlagTracingExporter, "otlphttp", "otlpgrpc"),
+	FlagTracingRatio: func(cmd *cli.Command) error {
+		if ratio := cmd.Float(FlagTracingRatio); ratio < 0 || ratio > 1 {
+			return fmt.Errorf("%v must be between 0 and 1", ratio)
+		}
+		return nil
+	},
+	FlagAdminAddr: func(cmd *cli.Command) error {
+		if addr := cmd.String(FlagAdminAddr); addr != "" {
+			_, _, err := net.SplitHostPort(addr)
+			return err
+		}
+		return nil
+	},
+	FlagAdminTLSCert:     requires(FlagAdminTLSCert, FlagAdminTLSKey),
+	FlagAdminTLSKey:      requires(FlagAdminTLSKey, FlagAdminTLSCert),
+	FlagAdminTLSClientCA: requires(FlagAdminTLSClientCA, FlagAdminTLSCert),
+	FlagAdminBasicAuth: func(cmd *cli.Command) error {
+		if auth := cmd.String(FlagAdminBasicAuth); auth != "" {
+			_, _, err := parseBasicAuth(auth)
+			return err
+		}
+		return nil
+	},
+}
+
+func nonNegativeInt(name string) func(cmd *cli.Command) error {
+	return func(cmd *cli.Command) error {
+		if cmd.Int(name) < 0 {
+			return errors.New("must not be negative")
+		}
+		return nil
+	}
+}
+
+// oneOf validates the flag is empty or one of the given values.
+func oneOf(name string, vals ...string) func(cmd *cli.Command) error {
+	return func(cmd *cli.Command) error {
+		if val := cmd.String(name); val != "" && !slices.Contains(vals, val) {
+			return fmt.Errorf("unsupported value %q, expected one of %s", val, strings.Join(vals, ", "))
+		}
+		return nil
+	}
+}
+
+// requires validates the other flag is set when the flag is not empty.
+func requires(name, other string) func(cmd *cli.Command) error {
+	return func(cmd *cli.Command) error {
+		if cmd.String(name) != "" && cmd.String(other) == "" {
+			return fmt.Errorf("requires --%s", other)
+		}
+		return nil
+	}
+}
+
+// validateStatsDSN validates the stats DSN, along with the options of its scheme.
+func validateStatsDSN(dsn string) error {
+	uri, err := parseStatsDSN(dsn)
+	if err != nil {
+		return err
+	}
+	switch uri.Scheme {
+	case "statsd", "statsd+tcp", "statsd+unix":
+		_, err = parseStatsdOptions(uri.Query())
+	case "prometheus", "prom", "victoriametrics", "vm":
+		_, err = parseMetricsServerOptions(uri)
+	case "otlphttp", "otlpgrpc":
+		_, err = parseOTLPOptions(uri.Query())
+	}
+	return err
+}
@@ -0,0 +1,189 @@
+package cmd_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/hamba/cmd/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v3"
+)
+
+func TestValidateFlags(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr []string
+	}{
+		{
+			name: "valid flags",
+			args: []string{
+				"--log.format=otel-json",
+				"--log.level=info,db=debug",
+				"--log.sink=stdout?format=console&level=info",
+				"--log.async",
+				"--log.async-policy=drop",
+				"--stats.dsn=statsd://localhost:8125",
+				"--profiling.dsn=https://example.com",
+				"--profiling.types=cpu",
+				"--tracing.exporter=otlphttp",
+				"--tracing.ratio=1",
+			},
+		},
+		{
+			name: "defaults",
+		},
+		{
+			name: "invalid flags",
+			args: []string{
+				"--log.format=xml",
+				"--log.level=loud",
+				"--log.redact=/(/",
+				"--log.max-size=-1",
+				"--log.async-queue-size=0",
+				"--log.async-policy=wait",
+				"--log.exporter=zipkin",
+				"--stats.dsn=datadog://localhost",
+				"--stats.interval=0s",
+				"--stats.runtime-interval=-1s",
+				"--profiling.dsn=localhost:4040",
+				"--profiling.types=disk",
+				"--tracing.exporter=jaeger",
+				"--tracing.ratio=1.5",
+				"--admin.addr=localhost",
+				"--admin.tls-key=key.pem",
+				"--admin.basic-auth=user",
+			},
+			wantErr: []string{
+				`invalid --log.format ($LOG_FORMAT): unsupported log format "xml"`,
+				`invalid --log.level ($LOG_LEVEL): `,
+				`invalid --log.redact ($LOG_REDACT): `,
+				`invalid --log.max-size ($LOG_MAX_SIZE): must not be negative`,
+				`invalid --log.async-queue-size ($LOG_ASYNC_QUEUE_SIZE): must be positive`,
+				`invalid --log.async-policy ($LOG_ASYNC_POLICY): unsupported log async policy "wait"`,
+				`invalid --log.exporter ($LOG_EXPORTER): unsupported value "zipkin", expected one of otlphttp, otlpgrpc`,
+				`invalid --stats.dsn ($STATS_DSN): unsupported stats reporter: datadog`,
+				`invalid --stats.interval ($STATS_INTERVAL): must be positive`,
+				`invalid --stats.runtime-interval ($STATS_RUNTIME_INTERVAL): must be positive`,
+				`invalid --profiling.dsn ($PROFILING_DSN): `,
+				`invalid --profiling.types ($PROFILING_TYPES): unsupported profile type "disk"`,
+				`invalid --tracing.exporter ($TRACING_EXPORTER): unsupported value "jaeger", expected one of otlphttp, otlpgrpc`,
+				`invalid --tracing.ratio ($TRACING_RATIO): 1.5 must be between 0 and 1`,
+				`invalid --admin.addr ($ADMIN_ADDR): address localhost: missing port in address`,
+				`invalid --admin.tls-key ($ADMIN_TLS_KEY): requires --admin.tls-cert`,
+				`invalid --admin.basic-auth ($ADMIN_BASIC_AUTH): must be in the form user:password`,
+			},
+		},
+		{
+			name: "invalid log outputs",
+			args: []string{
+				"--log.output=kafka://localhost",
+				"--log.sink=stdout?format=xml",
+			},
+			wantErr: []string{
+				`invalid --log.output ($LOG_OUTPUT): unsupported log output "kafka://localhost"`,
+				`invalid --log.sink ($LOG_SINK): cannot be combined with --log.output or --log.file` + "\n" +
+					`sink 1: unsupported log format "xml"`,
+			},
+		},
+		{
+			name: "invalid log sinks",
+			args: []string{
+				"--log.level=info",
+				"--log.sink=stdout?format=xml",
+				"--log.sink=syslog://tcp://localhost",
+				"--log.sink=stderr?level=debug",
+			},
+			wantErr: []string{
+				`invalid --log.sink ($LOG_SINK): sink 1: unsupported log format "xml"` + "\n" +
+					`sink 2: unsupported syslog address "tcp://localhost"` + "\n" +
+					`sink 3: level "debug" is more verbose than --log.level`,
+			},
+		},
+		{
+			name: "invalid statsd options",
+			args: []string{
+				"--stats.dsn=statsd://localhost:8125?flushBytes=big&sampelRate=0.5",
+			},
+			wantErr: []string{
+				`invalid --stats.dsn ($STATS_DSN): invalid flushBytes "big": must be a positive integer` + "\n" +
+					`unknown statsd option "sampelRate"`,
+			},
+		},
+		{
+			name: "invalid stats dsns",
+			args: []string{
+				"--stats.dsn=l2met://,statsd://localhost:8125?tags=influx,graphite",
+				"--stats.dsn=datadog://localhost",
+				"--stats.dsn=prom://user:secret@:9090?token=secret&tlsCertFile=cert.pem",
+				"--stats.dsn=otlphttp://localhost:4318?insecure=maybe&timeout=1s",
+			},
+			wantErr: []string{
+				`invalid --stats.dsn ($STATS_DSN): dsn 2: ` +
+					`invalid tags "influx,graphite": must be one of dogstatsd, influx, graphite` + "\n" +
+					`dsn 3: unsupported stats reporter: datadog` + "\n" +
+					`dsn 4: unknown metrics server option "tlsCertFile"` + "\n" +
+					`dsn 5: invalid insecure "maybe": must be a boolean` + "\n" +
+					`unknown otlp option "timeout"`,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var actionCalled bool
+			c := &cli.Command{
+				Flags:  cmd.MonitoringFlags,
+				Before: cmd.ValidateFlags,
+				Action: func(context.Context, *cli.Command) error {
+					actionCalled = true
+					return nil
+				},
+			}
+
+			err := c.Run(t.Context(), append([]string{"test"}, test.args...))
+
+			if len(test.wantErr) == 0 {
+				require.NoError(t, err)
+				assert.True(t, actionCalled)
+				return
+			}
+			require.Error(t, err)
+			assert.False(t, actionCalled)
+
+			var errs interface{ Unwrap() []error }
+			require.ErrorAs(t, err, &errs)
+			require.Len(t, errs.Unwrap(), len(test.wantErr))
+			for i, want := range test.wantErr {
+				var flagErr *cmd.FlagError
+				require.True(t, errors.As(errs.Unwrap()[i], &flagErr))
+				assert.Contains(t, flagErr.Error(), want)
+			}
+			assert.NotContains(t, err.Error(), "secret")
+		})
+	}
+}
+
+func TestValidateFlags_EnvVar(t *testing.T) {
+	t.Setenv("TEST_LOG_FORMAT", "xml")
+
+	c := &cli.Command{
+		Flags: cmd.Flags{
+			&cli.StringFlag{Name: cmd.FlagLogFormat, Sources: cli.EnvVars("TEST_LOG_FORMAT")},
+		},
+		Before: cmd.ValidateFlags,
+		Action: func(context.Context, *cli.Command) error {
+			return nil
+		},
+	}
+
+	err := c.Run(t.Context(), []string{"test"})
+
+	var flagErr *cmd.FlagError
+	require.ErrorAs(t, err, &flagErr)
+	assert.Equal(t, cmd.FlagLogFormat, flagErr.Flag)
+	assert.Equal(t, "TEST_LOG_FORMAT", flagErr.EnvVar)
+	assert.EqualError(t, flagErr, `invalid --log.format ($TEST_LOG_FORMAT): unsupported log format "xml"`)
+}